@@ -1,8 +1,19 @@
 package sofa
 
 import (
+	"crypto/md5"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path"
+	"sort"
+	"strings"
 )
 
 // Attachment represents files or other data which is attached to documents in the
@@ -25,7 +36,9 @@ type attachmentPutResponse struct {
 	OK  bool   `json:"ok"`
 }
 
-// GetAttachment gets the current attachment and returns
+// GetAttachment gets the current attachment and returns the content. The content is
+// checked against the MD5 digest sent by CouchDB & a DigestError is returned if it
+// does not match.
 func (db *Database) GetAttachment(docid, name, rev string) ([]byte, error) {
 	path := urlConcat(db.DocumentPath(docid), name)
 
@@ -36,18 +49,154 @@ func (db *Database) GetAttachment(docid, name, rev string) ([]byte, error) {
 		}
 	}
 
-	resp, err := db.con.Get(path, opts)
+	// Compressed content is requested explicitly so that it can be verified against the
+	// digest before being decompressed.
+	header := http.Header{"Accept-Encoding": {"gzip"}}
+
+	resp, err := db.con.headerRequest("GET", path, opts, header, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := attachmentBody(resp, name)
 	if err != nil {
 		return nil, err
 	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// AttachmentParams controls how an attachment is downloaded by Database.StreamAttachment.
+//   - Rev is the revision of the document to read the attachment from. The current
+//     revision is looked up & used if this is empty.
+//   - Range is sent as the HTTP Range header to request part of the attachment, for
+//     example "bytes=0-1023".
+//   - IfNoneMatch is the digest of a copy of the attachment which is already held. If
+//     the attachment still has this digest then no content is downloaded. Both the
+//     Attachment.Digest format ("md5-...") and a raw ETag are accepted.
+type AttachmentParams struct {
+	Rev         string
+	Range       string
+	IfNoneMatch string
+}
 
-	return io.ReadAll(resp.Body)
+// AttachmentReader streams the content of an attachment from the server. The details of the
+// attachment are read from the response headers and the content is available by reading from
+// the AttachmentReader, which must be closed after use.
+type AttachmentReader struct {
+	io.ReadCloser
+
+	// ContentType is the MIME type of the attachment.
+	ContentType string
+	// Length is the number of bytes in the response, which is the length of the requested
+	// range for partial responses. It is -1 when the length is unknown.
+	Length int64
+	// Digest is the MD5 digest of the whole attachment in the same format as Attachment.Digest.
+	Digest string
+	// Rev is the revision of the document the attachment was read from.
+	Rev string
+	// ContentRange is the value of the Content-Range header for partial responses.
+	ContentRange string
+	// NotModified is true when the digest provided in AttachmentParams.IfNoneMatch still
+	// matches the attachment, in which case there is no content to read.
+	NotModified bool
+}
+
+// StreamAttachment opens an attachment for reading without loading it into memory. Unlike
+// GetAttachment the request is not subject to the connection timeout, so it is suitable for
+// proxying very large attachments. The caller must close the returned AttachmentReader.
+// When the whole attachment is requested its digest is verified as it is read and a
+// DigestError is returned by Read instead of io.EOF if the content does not match.
+func (db *Database) StreamAttachment(docid, name string, params AttachmentParams) (*AttachmentReader, error) {
+	rev := params.Rev
+	if rev == "" {
+		// Attachment responses do not include the document revision so it is looked up
+		// first and then requested explicitly to make sure that the two match.
+		resp, err := db.con.Head(db.DocumentPath(docid), NewURLOptions())
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+
+		if rev, err = responseEtag(resp); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := NewURLOptions()
+	if err := opts.Set("rev", rev); err != nil {
+		return nil, err
+	}
+
+	header := http.Header{"Accept-Encoding": {"gzip"}}
+	if params.Range != "" {
+		header.Set("Range", params.Range)
+	}
+	if params.IfNoneMatch != "" {
+		header.Set("If-None-Match", digestEtag(params.IfNoneMatch))
+	}
+
+	path := urlConcat(db.DocumentPath(docid), name)
+	resp, err := db.con.urlRequest("GET", db.con.URL(path), opts, header, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := attachmentBody(resp, name)
+	if err != nil {
+		return nil, err
+	}
+
+	length := resp.ContentLength
+	if resp.Header.Get("Content-Encoding") != "" {
+		length = -1
+	}
+
+	return &AttachmentReader{
+		ReadCloser: body,
+
+		ContentType:  resp.Header.Get("Content-Type"),
+		Length:       length,
+		Digest:       etagDigest(resp.Header.Get("Etag")),
+		Rev:          rev,
+		ContentRange: resp.Header.Get("Content-Range"),
+		NotModified:  resp.StatusCode == http.StatusNotModified,
+	}, nil
+}
+
+// digestEtag converts an attachment digest into the quoted form of the ETag CouchDB
+// uses for the attachment.
+func digestEtag(digest string) string {
+	digest = strings.TrimPrefix(strings.Trim(digest, `"`), "md5-")
+	return `"` + digest + `"`
+}
+
+// etagDigest converts the ETag of an attachment response into the digest format used
+// in attachment stubs. Attachments without a stored MD5 use the document revision as
+// their ETag, in which case an empty digest is returned.
+func etagDigest(etag string) string {
+	etag = strings.TrimPrefix(strings.Trim(etag, `"`), "md5-")
+	if len(etag) != base64.StdEncoding.EncodedLen(md5.Size) {
+		return ""
+	}
+
+	return "md5-" + etag
 }
 
 // PutAttachment replaces the content of the attachment with new content read from an
 // io.Reader or creates it if it does not exist. If the provided rev is not the most
-// recent then an error will be returned from CouchDB.
+// recent then an error will be returned from CouchDB. The MD5 digest of the content is
+// sent along with it so that CouchDB rejects the upload if it was corrupted. This is sent
+// as a header for an io.ReadSeeker which can be seeked or as a trailer after the content
+// otherwise.
 func (db *Database) PutAttachment(docid, name string, doc io.Reader, rev string) (string, error) {
+	return db.putAttachment(docid, name, doc, rev, http.Header{})
+}
+
+// putAttachment uploads an attachment in the same way as PutAttachment, sending any extra
+// headers (such as the Content-Type) with the request.
+func (db *Database) putAttachment(docid, name string, doc io.Reader, rev string, header http.Header) (string, error) {
 	path := urlConcat(db.DocumentPath(docid), name)
 
 	opts := NewURLOptions()
@@ -57,7 +206,29 @@ func (db *Database) PutAttachment(docid, name string, doc io.Reader, rev string)
 		}
 	}
 
-	resp, err := db.con.Put(path, opts, doc)
+	var trailer *md5TrailerReader
+	if rs, ok := seekableReader(doc); ok {
+		sum, err := seekerMD5(rs)
+		if err != nil {
+			return "", err
+		}
+		header.Set("Content-MD5", sum)
+	} else if doc != nil {
+		trailer = newMD5TrailerReader(doc)
+		doc = trailer
+	}
+
+	req, err := db.con.newURLRequest("PUT", db.con.URL(path), opts, header, doc)
+	if err != nil {
+		return "", err
+	}
+
+	if trailer != nil {
+		req.ContentLength = -1
+		req.Trailer = trailer.trailer
+	}
+
+	resp, err := db.con.do(req, true)
 	if err != nil {
 		return "", err
 	}
@@ -101,3 +272,199 @@ func (db *Database) DeleteAttachment(docid, name, rev string) (string, error) {
 
 	return ar.Rev, nil
 }
+
+// PutWithAttachments saves a document together with any number of attachments in a single
+// multipart/related request. The document and all of the attachments are written atomically
+// as one new revision and the attachment content is streamed to the server without being
+// base64 encoded. Attachments which already exist on the document are kept as long as their
+// stubs are included in the "_attachments" field of the document. Content types are guessed
+// from the extension of each attachment name. CouchDB requires the length of each attachment
+// up front, which is found automatically for readers such as *bytes.Reader, *strings.Reader &
+// regular files; other readers must be wrapped using SizedReader.
+func (db *Database) PutWithAttachments(document interface{}, attachments map[string]io.Reader) (string, error) {
+	docMeta, err := documentMetadata(document)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(attachments))
+	for name := range attachments {
+		names = append(names, name)
+	}
+	// The attachment parts must be sent in the same order as they appear in the JSON
+	// document, which encoding/json always sorts by key.
+	sort.Strings(names)
+
+	docBytes, err := multipartDocument(document, names, attachments)
+	if err != nil {
+		return "", err
+	}
+
+	opts := NewURLOptions()
+	if docMeta.Rev != "" {
+		if err := opts.Set("rev", docMeta.Rev); err != nil {
+			return "", err
+		}
+	}
+
+	pr, pw := io.Pipe()
+	mpw := multipart.NewWriter(pw)
+
+	go func() {
+		pw.CloseWithError(writeMultipartDocument(mpw, docBytes, names, attachments))
+	}()
+
+	header := http.Header{}
+	header.Set("Content-Type", fmt.Sprintf("multipart/related; boundary=%q", mpw.Boundary()))
+
+	// The connection timeout is not used as it would limit how long the content can take to
+	// stream to the server.
+	resp, err := db.con.urlRequest("PUT", db.con.URL(db.DocumentPath(docMeta.ID)), opts, header, pr, false)
+	if err != nil {
+		pr.Close()
+		return "", err
+	}
+
+	ar := attachmentPutResponse{}
+	if err := unmarshalResponse(resp, &ar); err != nil {
+		return "", err
+	}
+
+	setDocumentRev(document, ar.Rev)
+	return ar.Rev, nil
+}
+
+// multipartDocument marshals the document to JSON and adds an entry to the "_attachments"
+// field for each of the attachments which will follow it in the request.
+func multipartDocument(document interface{}, names []string, attachments map[string]io.Reader) ([]byte, error) {
+	b, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	existing := map[string]Attachment{}
+	if raw, ok := fields["_attachments"]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &existing); err != nil {
+			return nil, err
+		}
+	}
+
+	stubs := map[string]interface{}{}
+	for name, att := range existing {
+		stubs[name] = att
+	}
+
+	for _, name := range names {
+		if attachments[name] == nil {
+			return nil, fmt.Errorf("attachment %q has a nil io.Reader", name)
+		}
+
+		// CouchDB requires the length of every attachment which follows the document
+		length, ok := readerLength(attachments[name])
+		if !ok {
+			return nil, fmt.Errorf("cannot determine the length of attachment %q: use SizedReader to provide it", name)
+		}
+
+		stubs[name] = followsStub{
+			ContentType: attachmentContentType(name),
+			Length:      length,
+			Follows:     true,
+		}
+	}
+
+	stubBytes, err := json.Marshal(stubs)
+	if err != nil {
+		return nil, err
+	}
+	fields["_attachments"] = stubBytes
+
+	return json.Marshal(fields)
+}
+
+// followsStub is the entry in "_attachments" for an attachment which follows the document in
+// a multipart/related request. Unlike Attachment the length is always included, even when
+// it is zero.
+type followsStub struct {
+	ContentType string `json:"content_type"`
+	Length      int64  `json:"length"`
+	Follows     bool   `json:"follows"`
+}
+
+// writeMultipartDocument writes the JSON document followed by the content of each attachment
+// as the parts of a multipart/related request body.
+func writeMultipartDocument(mpw *multipart.Writer, docBytes []byte, names []string, attachments map[string]io.Reader) error {
+	docPart, err := mpw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json"}})
+	if err != nil {
+		return err
+	}
+
+	if _, err := docPart.Write(docBytes); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		attPart, err := mpw.CreatePart(textproto.MIMEHeader{"Content-Type": {attachmentContentType(name)}})
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(attPart, attachments[name]); err != nil {
+			return err
+		}
+	}
+
+	return mpw.Close()
+}
+
+// attachmentContentType guesses the content type of an attachment from the extension
+// of the name, falling back to a generic binary type.
+func attachmentContentType(name string) string {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		return ct
+	}
+
+	return "application/octet-stream"
+}
+
+// sizedReader is an io.Reader with a known length, created by SizedReader.
+type sizedReader struct {
+	io.Reader
+	length int64
+}
+
+// SizedReader wraps an io.Reader with the number of bytes which will be read from it. This
+// allows readers whose length cannot be determined automatically to be used as attachments
+// with PutWithAttachments.
+func SizedReader(r io.Reader, length int64) io.Reader {
+	return sizedReader{Reader: r, length: length}
+}
+
+// readerLength attempts to find the number of bytes remaining in an io.Reader without
+// consuming any of the data.
+func readerLength(r io.Reader) (int64, bool) {
+	switch v := r.(type) {
+	case sizedReader:
+		return v.length, true
+	case interface{ Len() int }:
+		return int64(v.Len()), true
+	case *os.File:
+		fi, err := v.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return 0, false
+		}
+
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+
+		return fi.Size() - offset, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,338 @@
+package sofa
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS returns an fs.FS which provides read-only access to the attachments stored in the
+// Database. Each document is a directory containing the attachments of that document as
+// files, with any '/' characters in attachment names creating sub-directories. Design
+// documents are found inside a "_design" directory. File information is taken from the
+// attachment stubs and the files returned implement io.Seeker, so the FS can be used with
+// http.FileServer, template.ParseFS, fs.WalkDir etc.
+func (d *Database) FS() fs.FS {
+	return &attachmentFS{db: d}
+}
+
+type attachmentFS struct {
+	db *Database
+}
+
+// Open implements fs.FS for attachmentFS.
+func (fsys *attachmentFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		return fsys.openDocumentDir(name, "")
+	}
+
+	parts := strings.Split(name, "/")
+	docid, rest := parts[0], parts[1:]
+
+	if docid == "_design" {
+		if len(rest) == 0 {
+			return fsys.openDocumentDir(name, "_design/")
+		}
+
+		docid, rest = "_design/"+rest[0], rest[1:]
+	}
+
+	doc := struct {
+		DocumentMetadata
+		Attachments map[string]Attachment `json:"_attachments"`
+	}{}
+
+	if _, err := fsys.db.Get(&doc, docid, ""); err != nil {
+		return nil, fsPathError("open", name, err)
+	}
+
+	attName := strings.Join(rest, "/")
+	if stub, ok := doc.Attachments[attName]; ok && attName != "" {
+		return &attachmentFile{
+			db:    fsys.db,
+			docid: docid,
+			name:  attName,
+			rev:   doc.Rev,
+			info:  attachmentFileInfo{name: path.Base(name), stub: stub},
+		}, nil
+	}
+
+	prefix := ""
+	if attName != "" {
+		prefix = attName + "/"
+	}
+
+	var entries []fs.DirEntry
+	seen := map[string]bool{}
+	for fullName, stub := range doc.Attachments {
+		if !strings.HasPrefix(fullName, prefix) {
+			continue
+		}
+
+		entryName := strings.TrimPrefix(fullName, prefix)
+		if i := strings.Index(entryName, "/"); i >= 0 {
+			entryName = entryName[:i]
+			if !seen[entryName] {
+				entries = append(entries, fs.FileInfoToDirEntry(attachmentFileInfo{name: entryName, dir: true}))
+			}
+		} else {
+			entries = append(entries, fs.FileInfoToDirEntry(attachmentFileInfo{name: entryName, stub: stub}))
+		}
+		seen[entryName] = true
+	}
+
+	// A document with no attachments is still an empty directory but any other path
+	// must match at least one attachment.
+	if len(entries) == 0 && attName != "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return newAttachmentDir(name, entries), nil
+}
+
+// openDocumentDir lists the documents in the database which have IDs starting with prefix
+// as a directory.
+func (fsys *attachmentFS) openDocumentDir(name, prefix string) (fs.File, error) {
+	docs, err := fsys.db.ListDocuments()
+	if err != nil {
+		return nil, fsPathError("open", name, err)
+	}
+
+	var entries []fs.DirEntry
+	hasDesign := false
+	for _, row := range docs.Rows {
+		if !strings.HasPrefix(row.ID, prefix) {
+			continue
+		}
+
+		id := strings.TrimPrefix(row.ID, prefix)
+		if strings.HasPrefix(id, "_design/") {
+			hasDesign = true
+			continue
+		}
+
+		// Other documents containing a '/' cannot be represented by a valid path
+		if id == "" || strings.Contains(id, "/") {
+			continue
+		}
+
+		entries = append(entries, fs.FileInfoToDirEntry(attachmentFileInfo{name: id, dir: true}))
+	}
+
+	if hasDesign {
+		entries = append(entries, fs.FileInfoToDirEntry(attachmentFileInfo{name: "_design", dir: true}))
+	}
+
+	return newAttachmentDir(name, entries), nil
+}
+
+// fsPathError converts an error from the server into a *fs.PathError, using the standard
+// fs errors where there is an equivalent.
+func fsPathError(op, name string, err error) error {
+	switch {
+	case ErrorStatus(err, 404):
+		err = fs.ErrNotExist
+	case ErrorStatus(err, 401), ErrorStatus(err, 403):
+		err = fs.ErrPermission
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// attachmentFileInfo implements fs.FileInfo for both attachments & the directories
+// containing them.
+type attachmentFileInfo struct {
+	name string
+	dir  bool
+	stub Attachment
+}
+
+func (fi attachmentFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi attachmentFileInfo) Size() int64 {
+	return fi.stub.Length
+}
+
+func (fi attachmentFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
+
+// ModTime always returns the zero time because CouchDB does not record when
+// attachments were modified.
+func (fi attachmentFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (fi attachmentFileInfo) IsDir() bool {
+	return fi.dir
+}
+
+// Sys returns the Attachment stub for files and nil for directories.
+func (fi attachmentFileInfo) Sys() interface{} {
+	if fi.dir {
+		return nil
+	}
+
+	return fi.stub
+}
+
+// attachmentDir implements fs.ReadDirFile for documents and the directories created by
+// '/' characters in attachment names.
+type attachmentDir struct {
+	info    attachmentFileInfo
+	path    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func newAttachmentDir(name string, entries []fs.DirEntry) *attachmentDir {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return &attachmentDir{
+		info:    attachmentFileInfo{name: path.Base(name), dir: true},
+		path:    name,
+		entries: entries,
+	}
+}
+
+func (d *attachmentDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *attachmentDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: errors.New("is a directory")}
+}
+
+func (d *attachmentDir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile for attachmentDir.
+func (d *attachmentDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+
+	d.offset += n
+	return remaining[:n], nil
+}
+
+// attachmentFile implements fs.File and io.Seeker for a single attachment. The content is
+// streamed from the server when it is first read and seeking starts a new ranged request
+// from the new offset.
+type attachmentFile struct {
+	db    *Database
+	docid string
+	name  string
+	rev   string
+	info  attachmentFileInfo
+
+	offset int64
+	body   io.ReadCloser
+}
+
+func (f *attachmentFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *attachmentFile) Read(p []byte) (int, error) {
+	if f.body == nil {
+		if f.offset >= f.info.Size() {
+			return 0, io.EOF
+		}
+
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+// open starts streaming the attachment from the current offset.
+func (f *attachmentFile) open() error {
+	params := AttachmentParams{Rev: f.rev}
+	if f.offset > 0 {
+		params.Range = fmt.Sprintf("bytes=%d-", f.offset)
+	}
+
+	att, err := f.db.StreamAttachment(f.docid, f.name, params)
+	if err != nil {
+		return fsPathError("read", f.info.name, err)
+	}
+
+	// Compressed attachments do not support ranges so the whole attachment is sent
+	// and the content before the offset must be skipped.
+	if f.offset > 0 && att.ContentRange == "" {
+		if _, err := io.CopyN(io.Discard, att, f.offset); err != nil {
+			att.Close()
+			return err
+		}
+	}
+
+	f.body = att
+	return nil
+}
+
+func (f *attachmentFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.info.name, Err: fs.ErrInvalid}
+	}
+
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.info.name, Err: fs.ErrInvalid}
+	}
+
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *attachmentFile) Close() error {
+	if f.body == nil {
+		return nil
+	}
+
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
@@ -0,0 +1,124 @@
+package sofa
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func mockAttachmentFS() {
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)
+
+	gock.New(host).
+		Get("/fs_test_db/_all_docs").
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 3,
+			"offset":     0,
+			"rows": []map[string]interface{}{
+				{"id": "_design/app", "key": "_design/app", "value": map[string]string{"rev": DefaultFirstRev}},
+				{"id": "assets", "key": "assets", "value": map[string]string{"rev": DefaultSecondRev}},
+				{"id": "empty", "key": "empty", "value": map[string]string{"rev": DefaultFirstRev}},
+			},
+		})
+
+	gock.New(host).
+		Get("/fs_test_db/_design/app$").
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{
+			"_id":  "_design/app",
+			"_rev": DefaultFirstRev,
+			"_attachments": map[string]interface{}{
+				"index.html": map[string]interface{}{"content_type": "text/html", "length": 6, "stub": true},
+			},
+		}).
+		SetHeader("Etag", fmt.Sprintf(`"%s"`, DefaultFirstRev))
+
+	gock.New(host).
+		Get("/fs_test_db/assets$").
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{
+			"_id":  "assets",
+			"_rev": DefaultSecondRev,
+			"_attachments": map[string]interface{}{
+				"test.txt":      map[string]interface{}{"content_type": "text/plain", "length": len(AttachmentContent), "stub": true},
+				"css/site.css":  map[string]interface{}{"content_type": "text/css", "length": 13, "stub": true},
+				"css/print.css": map[string]interface{}{"content_type": "text/css", "length": 0, "stub": true},
+			},
+		}).
+		SetHeader("Etag", fmt.Sprintf(`"%s"`, DefaultSecondRev))
+
+	gock.New(host).
+		Get("/fs_test_db/empty$").
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{
+			"_id":  "empty",
+			"_rev": DefaultFirstRev,
+		}).
+		SetHeader("Etag", fmt.Sprintf(`"%s"`, DefaultFirstRev))
+
+	gock.New(host).
+		Get("/fs_test_db/_design/app/index.html").
+		Persist().
+		Reply(200).
+		BodyString("<html>")
+
+	gock.New(host).
+		Get("/fs_test_db/assets/test.txt").
+		MatchParam("rev", DefaultSecondRev).
+		Persist().
+		Reply(200).
+		BodyString(AttachmentContent).
+		SetHeader("Content-MD5", AttachmentContentMD5)
+
+	gock.New(host).
+		Get("/fs_test_db/assets/css/site.css").
+		Persist().
+		Reply(200).
+		BodyString("body {}\n.a {}")
+
+	gock.New(host).
+		Get("/fs_test_db/assets/css/print.css").
+		Persist().
+		Reply(200)
+
+	gock.New(host).
+		Get("/fs_test_db/missing").
+		Persist().
+		Reply(404).
+		JSON(map[string]string{"error": "not_found", "reason": "missing"})
+}
+
+func TestAttachmentFS(t *testing.T) {
+	defer gock.Off()
+	mockAttachmentFS()
+
+	con := globalTestConnections.Version2(t, true)
+	fsys := con.Database("fs_test_db").FS()
+
+	err := fstest.TestFS(fsys, "assets/test.txt", "assets/css/site.css", "assets/css/print.css", "_design/app/index.html", "empty")
+	st.Assert(t, err, nil)
+
+	content, err := fs.ReadFile(fsys, "assets/test.txt")
+	st.Assert(t, err, nil)
+	st.Assert(t, string(content), AttachmentContent)
+
+	info, err := fs.Stat(fsys, "assets/css/site.css")
+	st.Assert(t, err, nil)
+	st.Assert(t, info.Size(), int64(13))
+	st.Assert(t, info.Sys().(Attachment).ContentType, "text/css")
+
+	_, err = fsys.Open("missing/file.txt")
+	st.Assert(t, err.(*fs.PathError).Err, fs.ErrNotExist)
+
+	_, err = fsys.Open("assets/nothing.txt")
+	st.Assert(t, err.(*fs.PathError).Err, fs.ErrNotExist)
+}
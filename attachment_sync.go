@@ -0,0 +1,243 @@
+package sofa
+
+import (
+	"crypto/md5"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"sort"
+)
+
+// AttachmentSyncAction describes the change needed to bring a single attachment in line
+// with the local copy during an attachment sync.
+type AttachmentSyncAction string
+
+const (
+	// AttachmentAdded is used for files which do not yet exist as an attachment.
+	AttachmentAdded AttachmentSyncAction = "add"
+	// AttachmentUpdated is used for files whose content differs from the attachment.
+	AttachmentUpdated AttachmentSyncAction = "update"
+	// AttachmentDeleted is used for attachments which no longer exist as a file.
+	AttachmentDeleted AttachmentSyncAction = "delete"
+)
+
+// AttachmentSync describes a directory tree to be mirrored into document attachments by
+// Database.SyncAttachments.
+type AttachmentSync struct {
+	// Source is the directory tree to mirror, for example from os.DirFS.
+	Source fs.FS
+
+	// DocumentID is the document which every file is attached to, using the path of the
+	// file within Source as the attachment name. It is ignored if Documents is set.
+	DocumentID string
+
+	// Documents maps the path of each file within Source to the document & attachment name
+	// which it should be stored as, allowing a tree to be spread across several documents.
+	// Files for which an empty document ID is returned are skipped.
+	Documents func(path string) (docid, name string)
+
+	// DryRun causes the changes which would be made to be returned without changing
+	// anything on the server.
+	DryRun bool
+}
+
+// AttachmentSyncChange is a single change made (or which would be made in a dry run) by
+// Database.SyncAttachments.
+type AttachmentSyncChange struct {
+	Action      AttachmentSyncAction
+	DocumentID  string
+	Name        string
+	Path        string
+	ContentType string
+}
+
+// SyncAttachments mirrors a directory tree into document attachments. Files are uploaded
+// when no attachment exists or when the MD5 of the file differs from the digest of the
+// attachment, and attachments which have no matching file are deleted. Only documents which
+// at least one file maps to (and DocumentID, if set) are checked for attachments to delete.
+// Documents which do not exist are created. Content types are guessed from the extension of
+// the attachment names.
+func (d *Database) SyncAttachments(sync AttachmentSync) ([]AttachmentSyncChange, error) {
+	locate := sync.Documents
+	if locate == nil {
+		if sync.DocumentID == "" {
+			return nil, errors.New("attachment sync requires either a DocumentID or Documents function")
+		}
+
+		locate = func(path string) (string, string) {
+			return sync.DocumentID, path
+		}
+	}
+
+	files := map[string]map[string]string{}
+	if sync.Documents == nil {
+		files[sync.DocumentID] = map[string]string{}
+	}
+
+	err := fs.WalkDir(sync.Source, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		docid, name := locate(path)
+		if docid == "" {
+			return nil
+		}
+
+		if files[docid] == nil {
+			files[docid] = map[string]string{}
+		}
+		files[docid][name] = path
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	docids := make([]string, 0, len(files))
+	for docid := range files {
+		docids = append(docids, docid)
+	}
+	sort.Strings(docids)
+
+	var changes []AttachmentSyncChange
+	for _, docid := range docids {
+		docChanges, err := d.syncDocumentAttachments(sync, docid, files[docid])
+		changes = append(changes, docChanges...)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	return changes, nil
+}
+
+// syncDocumentAttachments makes the attachments of a single document match the provided
+// map of attachment names to file paths.
+func (d *Database) syncDocumentAttachments(sync AttachmentSync, docid string, files map[string]string) ([]AttachmentSyncChange, error) {
+	doc := struct {
+		DocumentMetadata
+		Attachments map[string]Attachment `json:"_attachments"`
+	}{}
+
+	// Encoding information is needed as the digest of a compressed attachment is
+	// calculated from the compressed content.
+	opts := NewURLOptions()
+	if err := opts.Set("att_encoding_info", true); err != nil {
+		return nil, err
+	}
+
+	if _, err := d.con.unmarshalRequest("GET", d.DocumentPath(docid), opts, nil, &doc); err != nil && !ErrorStatus(err, 404) {
+		return nil, err
+	}
+	rev := doc.Rev
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []AttachmentSyncChange
+	for _, name := range names {
+		change := AttachmentSyncChange{
+			Action:      AttachmentAdded,
+			DocumentID:  docid,
+			Name:        name,
+			Path:        files[name],
+			ContentType: attachmentContentType(name),
+		}
+
+		if stub, ok := doc.Attachments[name]; ok {
+			same, err := d.attachmentMatchesFile(sync.Source, files[name], docid, name, rev, stub)
+			if err != nil {
+				return changes, err
+			}
+
+			if same {
+				continue
+			}
+
+			change.Action = AttachmentUpdated
+		}
+
+		if !sync.DryRun {
+			f, err := sync.Source.Open(files[name])
+			if err != nil {
+				return changes, err
+			}
+
+			header := http.Header{"Content-Type": {attachmentContentType(name)}}
+			rev, err = d.putAttachment(docid, name, f, rev, header)
+			f.Close()
+			if err != nil {
+				return changes, err
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	var removed []string
+	for name := range doc.Attachments {
+		if _, ok := files[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+
+	for _, name := range removed {
+		if !sync.DryRun {
+			var err error
+			if rev, err = d.DeleteAttachment(docid, name, rev); err != nil {
+				return changes, err
+			}
+		}
+
+		changes = append(changes, AttachmentSyncChange{
+			Action:      AttachmentDeleted,
+			DocumentID:  docid,
+			Name:        name,
+			ContentType: doc.Attachments[name].ContentType,
+		})
+	}
+
+	return changes, nil
+}
+
+// attachmentMatchesFile checks whether the content of a file is the same as an existing
+// attachment. The digest of an uncompressed attachment is compared directly but for a
+// compressed attachment the content must be downloaded, unless the lengths differ.
+func (d *Database) attachmentMatchesFile(fsys fs.FS, path, docid, name, rev string, stub Attachment) (bool, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return false, err
+	}
+
+	if stub.Encoding == "" {
+		return md5Digest(h) == stub.Digest, nil
+	}
+
+	if size != stub.Length {
+		return false, nil
+	}
+
+	content, err := d.GetAttachment(docid, name, rev)
+	if err != nil {
+		return false, err
+	}
+
+	remote := md5.New()
+	remote.Write(content)
+
+	return md5Digest(h) == md5Digest(remote), nil
+}
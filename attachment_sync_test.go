@@ -0,0 +1,133 @@
+package sofa
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func getAttachmentSyncSource() fstest.MapFS {
+	return fstest.MapFS{
+		"app.js":     {Data: []byte("console.log('new')")},
+		"index.html": {Data: []byte("<html></html>")},
+		"test.txt":   {Data: []byte(AttachmentContent)},
+	}
+}
+
+func mockAttachmentSyncDocument() {
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/sync_test_db/assets").
+		MatchParam("att_encoding_info", "true").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"_id":  "assets",
+			"_rev": DefaultFirstRev,
+			"_attachments": map[string]interface{}{
+				"app.js":   map[string]interface{}{"content_type": "text/javascript", "digest": "md5-AAAAAAAAAAAAAAAAAAAAAA==", "length": 18, "stub": true},
+				"old.css":  map[string]interface{}{"content_type": "text/css", "digest": "md5-BBBBBBBBBBBBBBBBBBBBBB==", "length": 10, "stub": true},
+				"test.txt": map[string]interface{}{"content_type": "text/plain", "digest": AttachmentDigest, "length": len(AttachmentContent), "stub": true},
+			},
+		})
+}
+
+func TestSyncAttachmentsDryRun(t *testing.T) {
+	defer gock.Off()
+	mockAttachmentSyncDocument()
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("sync_test_db")
+
+	changes, err := db.SyncAttachments(AttachmentSync{
+		Source:     getAttachmentSyncSource(),
+		DocumentID: "assets",
+		DryRun:     true,
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, len(changes), 3)
+
+	st.Assert(t, changes[0].Action, AttachmentUpdated)
+	st.Assert(t, changes[0].Name, "app.js")
+	st.Assert(t, changes[0].Path, "app.js")
+
+	st.Assert(t, changes[1].Action, AttachmentAdded)
+	st.Assert(t, changes[1].Name, "index.html")
+	assertPrefix(t, changes[1].ContentType, "text/html")
+
+	st.Assert(t, changes[2].Action, AttachmentDeleted)
+	st.Assert(t, changes[2].Name, "old.css")
+
+	st.Assert(t, gock.IsDone(), true)
+}
+
+func TestSyncAttachments(t *testing.T) {
+	defer gock.Off()
+	mockAttachmentSyncDocument()
+
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)
+
+	gock.New(host).
+		Put("/sync_test_db/assets/app.js").
+		MatchParam("rev", DefaultFirstRev).
+		MatchHeader("Content-Type", "javascript").
+		Reply(201).
+		JSON(map[string]interface{}{"ok": true, "id": "assets", "rev": DefaultSecondRev})
+
+	gock.New(host).
+		Put("/sync_test_db/assets/index.html").
+		MatchParam("rev", DefaultSecondRev).
+		MatchHeader("Content-Type", "text/html").
+		Reply(201).
+		JSON(map[string]interface{}{"ok": true, "id": "assets", "rev": DefaultThirdRev})
+
+	gock.New(host).
+		Delete("/sync_test_db/assets/old.css").
+		MatchParam("rev", DefaultThirdRev).
+		Reply(200).
+		JSON(map[string]interface{}{"ok": true, "id": "assets", "rev": "4-3ac2e44b6bb76c4a6b0e3cc6f8f3f3a1"})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("sync_test_db")
+
+	changes, err := db.SyncAttachments(AttachmentSync{
+		Source:     getAttachmentSyncSource(),
+		DocumentID: "assets",
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, len(changes), 3)
+	st.Assert(t, gock.IsDone(), true)
+}
+
+func TestSyncAttachmentsDocuments(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/sync_test_db/new").
+		Reply(404).
+		JSON(map[string]string{"error": "not_found", "reason": "missing"})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("sync_test_db")
+
+	changes, err := db.SyncAttachments(AttachmentSync{
+		Source: getAttachmentSyncSource(),
+		Documents: func(path string) (string, string) {
+			if path == "test.txt" {
+				return "", ""
+			}
+			return "new", "static/" + path
+		},
+		DryRun: true,
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, len(changes), 2)
+	st.Assert(t, changes[0].Action, AttachmentAdded)
+	st.Assert(t, changes[0].DocumentID, "new")
+	st.Assert(t, changes[0].Name, "static/app.js")
+	st.Assert(t, changes[1].Name, "static/index.html")
+}
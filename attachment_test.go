@@ -1,9 +1,18 @@
 package sofa
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/h2non/gock"
 	"github.com/nbio/st"
 )
 
@@ -63,3 +72,245 @@ func TestAttachmentReal(t *testing.T) {
 	err = con.DeleteDatabase(AttachmentTestDB)
 	st.Assert(t, err, nil)
 }
+
+func TestPutWithAttachments(t *testing.T) {
+	defer gock.Off()
+
+	var parts []string
+	var partTypes []string
+	var sentDoc map[string]interface{}
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Put("/attachment_test_db/fruit1").
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/related" {
+				return false, err
+			}
+
+			mr := multipart.NewReader(req.Body, params["boundary"])
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					return false, err
+				}
+
+				b, err := io.ReadAll(part)
+				if err != nil {
+					return false, err
+				}
+
+				parts = append(parts, string(b))
+				partTypes = append(partTypes, part.Header.Get("Content-Type"))
+			}
+
+			return true, json.Unmarshal([]byte(parts[0]), &sentDoc)
+		}).
+		Reply(201).
+		JSON(map[string]interface{}{
+			"ok":  true,
+			"id":  "fruit1",
+			"rev": DefaultFirstRev,
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	doc := &taggedTestDoc{
+		ID:      "fruit1",
+		Content: "apple",
+	}
+
+	rev, err := db.PutWithAttachments(doc, map[string]io.Reader{
+		"b.json":           strings.NewReader(`{"b":true}`),
+		AttachmentFileName: strings.NewReader(AttachmentContent),
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, rev, DefaultFirstRev)
+	st.Assert(t, doc.Rev, DefaultFirstRev)
+
+	st.Assert(t, len(parts), 3)
+	st.Assert(t, partTypes[0], "application/json")
+	st.Assert(t, parts[1], `{"b":true}`)
+	st.Assert(t, partTypes[1], "application/json")
+	st.Assert(t, parts[2], AttachmentContent)
+	assertPrefix(t, partTypes[2], "text/plain")
+
+	st.Assert(t, sentDoc["_id"], "fruit1")
+	st.Assert(t, sentDoc["content"], "apple")
+
+	atts := sentDoc["_attachments"].(map[string]interface{})
+	st.Assert(t, atts[AttachmentFileName].(map[string]interface{})["follows"], true)
+	st.Assert(t, atts[AttachmentFileName].(map[string]interface{})["length"], float64(len(AttachmentContent)))
+	st.Assert(t, atts["b.json"].(map[string]interface{})["follows"], true)
+}
+
+func TestPutWithAttachmentsLength(t *testing.T) {
+	defer gock.Off()
+
+	var sentDoc map[string]interface{}
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Put("/attachment_test_db/fruit1").
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			if err != nil {
+				return false, err
+			}
+
+			part, err := multipart.NewReader(req.Body, params["boundary"]).NextPart()
+			if err != nil {
+				return false, err
+			}
+
+			return true, json.NewDecoder(part).Decode(&sentDoc)
+		}).
+		Reply(201).
+		JSON(map[string]interface{}{
+			"ok":  true,
+			"id":  "fruit1",
+			"rev": DefaultFirstRev,
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	doc := &taggedTestDoc{ID: "fruit1"}
+
+	// The length of a plain io.Reader cannot be found so it is rejected before sending
+	_, err := db.PutWithAttachments(doc, map[string]io.Reader{
+		AttachmentFileName: struct{ io.Reader }{strings.NewReader(AttachmentContent)},
+	})
+	st.Reject(t, err, nil)
+
+	_, err = db.PutWithAttachments(doc, map[string]io.Reader{
+		"empty.txt":        strings.NewReader(""),
+		AttachmentFileName: SizedReader(struct{ io.Reader }{strings.NewReader(AttachmentContent)}, int64(len(AttachmentContent))),
+	})
+	st.Assert(t, err, nil)
+
+	atts := sentDoc["_attachments"].(map[string]interface{})
+	st.Assert(t, atts["empty.txt"].(map[string]interface{})["length"], float64(0))
+	st.Assert(t, atts[AttachmentFileName].(map[string]interface{})["length"], float64(len(AttachmentContent)))
+}
+
+// slowReader returns each byte of its content after a delay.
+type slowReader struct {
+	content string
+	delay   time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.content == "" {
+		return 0, io.EOF
+	}
+
+	time.Sleep(r.delay)
+	n := copy(p[:1], r.content)
+	r.content = r.content[n:]
+	return n, nil
+}
+
+func TestPutWithAttachmentsSlow(t *testing.T) {
+	defer gock.Off()
+
+	var body string
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Put("/attachment_test_db/fruit1").
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				return false, err
+			}
+			body = string(b)
+
+			// The request must still be live after all of the content has been sent
+			return req.Context().Err() == nil, nil
+		}).
+		Reply(201).
+		JSON(map[string]interface{}{
+			"ok":  true,
+			"id":  "fruit1",
+			"rev": DefaultFirstRev,
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	con.timeout = 10 * time.Millisecond
+	db := con.Database(AttachmentTestDB)
+
+	content := &slowReader{content: AttachmentContent, delay: 2 * time.Millisecond}
+
+	rev, err := db.PutWithAttachments(&taggedTestDoc{ID: "fruit1"}, map[string]io.Reader{
+		AttachmentFileName: SizedReader(content, int64(len(AttachmentContent))),
+	})
+	st.Assert(t, err, nil)
+	st.Assert(t, rev, DefaultFirstRev)
+	assertPrefix(t, body, "--")
+	st.Assert(t, strings.Contains(body, AttachmentContent), true)
+}
+
+const AttachmentDigest = "md5-1rm+KhuliTR07qnK1N0A/A=="
+
+func TestStreamAttachment(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Head("/attachment_test_db/fruit1").
+		Reply(200).
+		SetHeader("Etag", fmt.Sprintf(`"%s"`, DefaultSecondRev))
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/attachment_test_db/fruit1/test.txt").
+		MatchParam("rev", DefaultSecondRev).
+		MatchHeader("Range", "^bytes=6-11$").
+		Reply(206).
+		BodyString(AttachmentContent[6:12]).
+		SetHeader("Content-Type", "text/plain").
+		SetHeader("Content-Range", fmt.Sprintf("bytes 6-11/%d", len(AttachmentContent))).
+		SetHeader("Etag", `"1rm+KhuliTR07qnK1N0A/A=="`)
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	att, err := db.StreamAttachment("fruit1", AttachmentFileName, AttachmentParams{Range: "bytes=6-11"})
+	st.Assert(t, err, nil)
+	defer att.Close()
+
+	content, err := io.ReadAll(att)
+	st.Assert(t, err, nil)
+
+	st.Assert(t, string(content), "Couchy")
+	st.Assert(t, att.ContentType, "text/plain")
+	st.Assert(t, att.Digest, AttachmentDigest)
+	st.Assert(t, att.Rev, DefaultSecondRev)
+	st.Assert(t, att.ContentRange, fmt.Sprintf("bytes 6-11/%d", len(AttachmentContent)))
+	st.Assert(t, att.NotModified, false)
+}
+
+func TestStreamAttachmentNotModified(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/attachment_test_db/fruit1/test.txt").
+		MatchParam("rev", DefaultFirstRev).
+		MatchHeader("If-None-Match", regexp.QuoteMeta(`"1rm+KhuliTR07qnK1N0A/A=="`)).
+		Reply(304).
+		SetHeader("Etag", `"1rm+KhuliTR07qnK1N0A/A=="`)
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	att, err := db.StreamAttachment("fruit1", AttachmentFileName, AttachmentParams{
+		Rev:         DefaultFirstRev,
+		IfNoneMatch: AttachmentDigest,
+	})
+	st.Assert(t, err, nil)
+	defer att.Close()
+
+	st.Assert(t, att.NotModified, true)
+	st.Assert(t, att.Digest, AttachmentDigest)
+}
@@ -0,0 +1,351 @@
+package sofa
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// collationClass gives the order of the different types of JSON value when collated by
+// CouchDB: null < false < true < numbers < strings < arrays < objects.
+func collationClass(v interface{}) int {
+	switch val := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if val {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+
+	return 6
+}
+
+// Collate compares two values using the same ordering as CouchDB uses for view keys,
+// returning -1 if a sorts before b, 1 if a sorts after b and 0 if they are equal. Values of
+// different types sort in the order null < false < true < numbers < strings < arrays <
+// objects, arrays are compared element by element and strings are compared using the
+// Unicode Collation Algorithm with the root locale, like the ICU collation used by CouchDB.
+// The collation data may differ slightly from the version of ICU used by a particular
+// server for rarely used characters. Values which are not already one of
+// the types produced by unmarshalling JSON into an interface{} (such as ints or structs)
+// are converted using a JSON round trip first.
+func Collate(a, b interface{}) int {
+	a, b = jsonValue(a), jsonValue(b)
+
+	ca, cb := collationClass(a), collationClass(b)
+	if ca != cb {
+		return compareInts(ca, cb)
+	}
+
+	switch av := a.(type) {
+	case float64:
+		bv := b.(float64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case string:
+		return collateStrings(av, b.(string))
+	case []interface{}:
+		bv := b.([]interface{})
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := Collate(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(av), len(bv))
+	case map[string]interface{}:
+		return collateObjects(av, b.(map[string]interface{}))
+	}
+
+	return 0
+}
+
+// collateObjects compares two objects by comparing each of their keys & values in turn.
+// CouchDB compares the members in the order they appear in the JSON, which is not preserved
+// by a map, so the keys are compared in sorted order instead.
+func collateObjects(a, b map[string]interface{}) int {
+	ak, bk := sortedKeys(a), sortedKeys(b)
+	for i := 0; i < len(ak) && i < len(bk); i++ {
+		if c := collateStrings(ak[i], bk[i]); c != 0 {
+			return c
+		}
+		if c := Collate(a[ak[i]], b[bk[i]]); c != 0 {
+			return c
+		}
+	}
+
+	return compareInts(len(ak), len(bk))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return collateStrings(keys[i], keys[j]) < 0
+	})
+
+	return keys
+}
+
+// rawCollationClass gives the order of the different types of JSON value when collated by
+// CouchDB using raw collation, which follows the Erlang term order of the decoded JSON:
+// numbers < false < null < true < objects < arrays < strings.
+func rawCollationClass(v interface{}) int {
+	switch val := v.(type) {
+	case float64:
+		return 0
+	case bool:
+		if val {
+			return 3
+		}
+		return 1
+	case nil:
+		return 2
+	case map[string]interface{}:
+		return 4
+	case []interface{}:
+		return 5
+	}
+
+	return 6
+}
+
+// CollateRaw compares two values using the raw collation used by CouchDB for the keys of
+// _all_docs & views with the "raw" collation option, returning -1 if a sorts before b, 1 if
+// a sorts after b and 0 if they are equal. Strings are compared by their raw bytes and
+// values of different types sort in the order numbers < false < null < true < objects <
+// arrays < strings. Values are converted in the same way as for Collate.
+func CollateRaw(a, b interface{}) int {
+	a, b = jsonValue(a), jsonValue(b)
+
+	ca, cb := rawCollationClass(a), rawCollationClass(b)
+	if ca != cb {
+		return compareInts(ca, cb)
+	}
+
+	switch av := a.(type) {
+	case float64:
+		bv := b.(float64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(av, b.(string))
+	case []interface{}:
+		bv := b.([]interface{})
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := CollateRaw(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(av), len(bv))
+	case map[string]interface{}:
+		bv := b.(map[string]interface{})
+		ak, bk := rawSortedKeys(av), rawSortedKeys(bv)
+		for i := 0; i < len(ak) && i < len(bk); i++ {
+			if c := strings.Compare(ak[i], bk[i]); c != 0 {
+				return c
+			}
+			if c := CollateRaw(av[ak[i]], bv[bk[i]]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(ak), len(bk))
+	}
+
+	return 0
+}
+
+func rawSortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// stringCollators holds collators for the root locale, which is used by CouchDB (through
+// ICU) to compare strings. A collate.Collator is not safe for concurrent use so they are
+// pooled.
+var stringCollators = sync.Pool{
+	New: func() interface{} {
+		return collate.New(language.Und)
+	},
+}
+
+// collateStrings compares strings using the Unicode Collation Algorithm with the root
+// locale, as CouchDB does using ICU: punctuation & whitespace sort before digits, which
+// sort before letters, accented letters sort with their base letters and case is only
+// significant (lowercase first) when the strings are otherwise equal.
+func collateStrings(a, b string) int {
+	c := stringCollators.Get().(*collate.Collator)
+	defer stringCollators.Put(c)
+
+	return c.CompareString(a, b)
+}
+
+// CompareRows compares two view rows in the order CouchDB returns them from a view using
+// the default collation: by key using Collate and then by document ID. Document IDs are
+// always compared by their raw bytes, as CouchDB does. Rows from _all_docs & from views
+// using raw collation are ordered differently and should be compared with CompareRowsRaw.
+func CompareRows(a, b Row) int {
+	if c := Collate(a.Key, b.Key); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.ID, b.ID)
+}
+
+// CompareRowsRaw compares two rows in the order CouchDB returns them from _all_docs or a view
+// using raw collation: by key using CollateRaw and then by document ID.
+func CompareRowsRaw(a, b Row) int {
+	if c := CollateRaw(a.Key, b.Key); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.ID, b.ID)
+}
+
+// SortRows sorts view rows into the order CouchDB would return them from a view using the
+// default collation, which is useful when rows from several queries or databases are
+// combined. Use SortRowsRaw for rows from _all_docs.
+func SortRows(rows []Row, descending bool) {
+	sortRows(rows, descending, CompareRows)
+}
+
+// SortRowsRaw sorts rows into the order CouchDB would return them from _all_docs or a view
+// using raw collation.
+func SortRowsRaw(rows []Row, descending bool) {
+	sortRows(rows, descending, CompareRowsRaw)
+}
+
+func sortRows(rows []Row, descending bool, compare func(a, b Row) int) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if descending {
+			return compare(rows[i], rows[j]) > 0
+		}
+		return compare(rows[i], rows[j]) < 0
+	})
+}
+
+// MergeRows merges lists of view rows which are each already sorted (for example the
+// results of the same query against several databases) into a single sorted list, using
+// the default view collation. Rows which compare equal keep the order of the lists they
+// came from. Use MergeRowsRaw for rows from _all_docs.
+func MergeRows(descending bool, lists ...[]Row) []Row {
+	return mergeRows(descending, CompareRows, lists)
+}
+
+// MergeRowsRaw merges lists of rows from _all_docs or a view using raw collation which are
+// each already sorted into a single sorted list.
+func MergeRowsRaw(descending bool, lists ...[]Row) []Row {
+	return mergeRows(descending, CompareRowsRaw, lists)
+}
+
+func mergeRows(descending bool, compare func(a, b Row) int, lists [][]Row) []Row {
+	total := 0
+	for _, list := range lists {
+		total += len(list)
+	}
+
+	merged := make([]Row, 0, total)
+	pos := make([]int, len(lists))
+	for len(merged) < total {
+		next := -1
+		for i, list := range lists {
+			if pos[i] >= len(list) {
+				continue
+			}
+
+			if next < 0 {
+				next = i
+				continue
+			}
+
+			c := compare(list[pos[i]], lists[next][pos[next]])
+			if (!descending && c < 0) || (descending && c > 0) {
+				next = i
+			}
+		}
+
+		merged = append(merged, lists[next][pos[next]])
+		pos[next]++
+	}
+
+	return merged
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// jsonValue converts a value to the equivalent value produced by unmarshalling JSON into
+// an interface{}, so that it can be collated. Values which cannot be converted are
+// returned unchanged.
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil, bool, float64, string:
+		return v
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i := range val {
+			out[i] = jsonValue(val[i])
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k := range val {
+			out[k] = jsonValue(val[k])
+		}
+		return out
+	case *InterfaceParameter:
+		if val == nil {
+			return nil
+		}
+		return jsonValue(val.innerVal)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+
+	return out
+}
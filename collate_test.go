@@ -0,0 +1,136 @@
+package sofa
+
+import (
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func TestCollate(t *testing.T) {
+	ordered := []interface{}{
+		nil,
+		false,
+		true,
+		-1,
+		2.5,
+		3,
+		" ",
+		"1",
+		"a",
+		"A",
+		"aa",
+		"b",
+		"B",
+		"\ufff0",
+		[]interface{}{},
+		[]interface{}{"a"},
+		[]interface{}{"a", 1},
+		[]interface{}{"b"},
+		map[string]interface{}{},
+		map[string]interface{}{"a": 1},
+		map[string]interface{}{"a": 2},
+		map[string]interface{}{"a": 2, "b": 1},
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			st.Expect(t, Collate(ordered[i], ordered[j]), compareInts(i, j), i*len(ordered)+j)
+		}
+	}
+}
+
+func TestCollateStrings(t *testing.T) {
+	// Punctuation & accents are ordered as by the ICU root collation
+	ordered := []string{
+		"_",
+		"-",
+		",",
+		"Ångström",
+		"e",
+		"E",
+		"é",
+		"É",
+		"f",
+		"resume",
+		"résumé",
+		"resumes",
+		"zoe",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			st.Expect(t, Collate(ordered[i], ordered[j]), compareInts(i, j), i*len(ordered)+j)
+		}
+	}
+
+	st.Assert(t, Collate("émile", "zoe"), -1)
+}
+
+func TestSortRows(t *testing.T) {
+	rows := []Row{
+		{ID: "c", Key: []interface{}{"b", 1}},
+		{ID: "b", Key: "a"},
+		{ID: "a", Key: []interface{}{"b", 1}},
+		{ID: "d", Key: nil},
+		{ID: "e", Key: map[string]interface{}{}},
+	}
+
+	SortRows(rows, false)
+	st.Assert(t, rowIDs(rows), []string{"d", "b", "a", "c", "e"})
+
+	SortRows(rows, true)
+	st.Assert(t, rowIDs(rows), []string{"e", "c", "a", "b", "d"})
+}
+
+func TestMergeRows(t *testing.T) {
+	first := []Row{{ID: "a", Key: 1}, {ID: "c", Key: 3}, {ID: "e", Key: "x"}}
+	second := []Row{{ID: "b", Key: 2}, {ID: "d", Key: 3}}
+
+	st.Assert(t, rowIDs(MergeRows(false, first, nil, second)), []string{"a", "b", "c", "d", "e"})
+
+	SortRows(first, true)
+	SortRows(second, true)
+	st.Assert(t, rowIDs(MergeRows(true, first, second)), []string{"e", "d", "c", "b", "a"})
+}
+
+func TestCollateRaw(t *testing.T) {
+	ordered := []interface{}{
+		-1,
+		2.5,
+		false,
+		nil,
+		true,
+		map[string]interface{}{},
+		map[string]interface{}{"a": 1},
+		map[string]interface{}{"b": 1},
+		[]interface{}{},
+		[]interface{}{"a"},
+		[]interface{}{"a", 1},
+		"",
+		"A",
+		"B",
+		"a",
+		"aa",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			st.Expect(t, CollateRaw(ordered[i], ordered[j]), compareInts(i, j), i*len(ordered)+j)
+		}
+	}
+}
+
+func TestSortRowsDocumentIDs(t *testing.T) {
+	// Ties between equal keys are broken by the raw bytes of the document IDs
+	rows := []Row{{ID: "a", Key: "x"}, {ID: "B", Key: "x"}, {ID: "b", Key: "x"}}
+	SortRows(rows, false)
+	st.Assert(t, rowIDs(rows), []string{"B", "a", "b"})
+
+	first := []Row{{ID: "a", Key: "a"}, {ID: "c", Key: "c"}}
+	second := []Row{{ID: "B", Key: "B"}}
+	st.Assert(t, rowIDs(MergeRows(false, first, second)), []string{"a", "B", "c"})
+	st.Assert(t, rowIDs(MergeRowsRaw(false, first, second)), []string{"B", "a", "c"})
+
+	SortRowsRaw(first, true)
+	st.Assert(t, rowIDs(first), []string{"c", "a"})
+}
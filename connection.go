@@ -19,6 +19,9 @@ type Connection struct {
 
 	url     *url.URL
 	timeout time.Duration
+
+	// version is the major version of the server, or 0 if it is not known.
+	version int
 }
 
 func newConnection(serverURL string, timeout time.Duration, auth Authenticator) (*Connection, error) {
@@ -70,10 +73,27 @@ func (con *Connection) URL(path string) url.URL {
 // Request also checks the response status and returns a ResponseError if an error HTTP
 // statuscode is received.
 func (con *Connection) Request(method, path string, opts Options, body io.Reader) (resp *http.Response, err error) {
-	return con.urlRequest(method, con.URL(path), opts, body, true)
+	return con.urlRequest(method, con.URL(path), opts, nil, body, true)
+}
+
+// headerRequest performs a request in the same way as Request but allows extra headers to be
+// sent, replacing any default headers with the same name.
+func (con *Connection) headerRequest(method, path string, opts Options, header http.Header, body io.Reader) (resp *http.Response, err error) {
+	return con.urlRequest(method, con.URL(path), opts, header, body, true)
+}
+
+func (con *Connection) urlRequest(method string, durl url.URL, opts Options, header http.Header, body io.Reader, doTimeout bool) (resp *http.Response, err error) {
+	req, err := con.newURLRequest(method, durl, opts, header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return con.do(req, doTimeout)
 }
 
-func (con *Connection) urlRequest(method string, durl url.URL, opts Options, body io.Reader, doTimeout bool) (resp *http.Response, err error) {
+// newURLRequest creates a http.Request for the server with any extra headers & authentication
+// information added, ready to be sent with do.
+func (con *Connection) newURLRequest(method string, durl url.URL, opts Options, header http.Header, body io.Reader) (*http.Request, error) {
 	durl.RawQuery = opts.Encode()
 
 	req, err := newRequest(method, durl.String(), body)
@@ -81,9 +101,19 @@ func (con *Connection) urlRequest(method string, durl url.URL, opts Options, bod
 		return nil, err
 	}
 
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
 	// Let the Authenticator add info to the request.
 	con.auth.Authenticate(req)
 
+	return req, nil
+}
+
+// do sends a request created by newURLRequest and checks the response status, returning a
+// ResponseError if an error HTTP statuscode is received.
+func (con *Connection) do(req *http.Request, doTimeout bool) (resp *http.Response, err error) {
 	// Set timeout on the request if it was needed (so not for long-polling etc.)
 	if doTimeout {
 		ctx, cancel := context.WithTimeout(req.Context(), con.timeout)
@@ -233,7 +263,24 @@ func (con *Connection) Databases() (databases []*Database, err error) {
 // CreateDatabase creates a new database on the CouchDB server and returns a
 // pointer to a Database initialised with the new values.
 func (con *Connection) CreateDatabase(name string) (*Database, error) {
-	resp, err := con.Put(name, NewURLOptions(), nil)
+	return con.createDatabase(name, NewURLOptions())
+}
+
+// CreatePartitionedDatabase creates a new partitioned database on the CouchDB server
+// (CouchDB 3+). Every document ID in a partitioned database must be of the form
+// "partition:id" and the documents can be queried one partition at a time using
+// Database.Partition.
+func (con *Connection) CreatePartitionedDatabase(name string) (*Database, error) {
+	opts := NewURLOptions()
+	if err := opts.Set("partitioned", true); err != nil {
+		return nil, err
+	}
+
+	return con.createDatabase(name, opts)
+}
+
+func (con *Connection) createDatabase(name string, opts Options) (*Database, error) {
+	resp, err := con.Put(name, opts, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -27,6 +27,8 @@ func NewConnection(serverURL string, timeout time.Duration, auth Authenticator)
         return nil, err
     }
 
+    con.version = 1
+
     return &CouchDB1Connection{con}, nil
 }
 
@@ -38,6 +40,8 @@ func NewConnection2(serverURL string, timeout time.Duration, auth Authenticator)
         return nil, err
     }
 
+    con.version = 2
+
     return &CouchDB2Connection{con}, nil
 }
 
@@ -49,6 +53,8 @@ func NewConnection3(serverURL string, timeout time.Duration, auth Authenticator)
         return nil, err
     }
 
+    con.version = 3
+
     return &CouchDB3Connection{con}, nil
 }
 
@@ -8,17 +8,23 @@ import (
 
 // DatabaseMetadata contains information about the database in CouchDB.
 type DatabaseMetadata struct {
-	CompactRunning     bool         `json:"compact_running"`
-	Name               string       `json:"db_name"`
-	DocCount           int          `json:"doc_count"`
-	DelCount           int          `json:"doc_del_count"`
-	InstanceStartTime  string       `json:"instance_start_time"`
-	DataSize           int          `json:"data_size"`
-	DiskSize           int          `json:"disk_size"`
-	DiskFormatVersion  int          `json:"disk_format_version"`
-	PurgeSeq           AlwaysString `json:"purge_seq"`
-	UpdateSeq          AlwaysString `json:"update_seq"`
-	CommittedUpdateSeq AlwaysString `json:"committed_update_seq"`
+	CompactRunning     bool          `json:"compact_running"`
+	Name               string        `json:"db_name"`
+	DocCount           int           `json:"doc_count"`
+	DelCount           int           `json:"doc_del_count"`
+	InstanceStartTime  string        `json:"instance_start_time"`
+	DataSize           int           `json:"data_size"`
+	DiskSize           int           `json:"disk_size"`
+	DiskFormatVersion  int           `json:"disk_format_version"`
+	PurgeSeq           AlwaysString  `json:"purge_seq"`
+	UpdateSeq          AlwaysString  `json:"update_seq"`
+	CommittedUpdateSeq AlwaysString  `json:"committed_update_seq"`
+	Props              DatabaseProps `json:"props"`
+}
+
+// DatabaseProps contains the properties the database was created with (CouchDB 3+).
+type DatabaseProps struct {
+	Partitioned bool `json:"partitioned"`
 }
 
 // Database represents a CouchDB database & provides methods to access documents in the database.
@@ -30,7 +36,7 @@ type Database struct {
 
 // Get retrieves a single document from the database and unmarshals it into the
 // provided interface.
-func (d *Database) Get(document Document, id, rev string) (string, error) {
+func (d *Database) Get(document interface{}, id, rev string) (string, error) {
 	path := d.DocumentPath(id)
 
 	var opts = NewURLOptions()
@@ -49,9 +55,14 @@ func (d *Database) Get(document Document, id, rev string) (string, error) {
 }
 
 // Put marshals the provided document into JSON and the sends it to the CouchDB server
-// with a PUT request. This allows modification of the document on the server.
-func (d *Database) Put(document Document) (string, error) {
-	docMeta := document.Metadata()
+// with a PUT request. This allows modification of the document on the server. The
+// document must either implement Document or be a struct with tagged ID & revision
+// fields, in which case the new revision is stored in the struct on success.
+func (d *Database) Put(document interface{}) (string, error) {
+	docMeta, err := documentMetadata(document)
+	if err != nil {
+		return "", err
+	}
 	path := d.DocumentPath(docMeta.ID)
 
 	var opts = NewURLOptions()
@@ -73,12 +84,22 @@ func (d *Database) Put(document Document) (string, error) {
 		return "", err
 	}
 
-	return responseEtag(resp)
+	rev, err := responseEtag(resp)
+	if err != nil {
+		return "", err
+	}
+
+	setDocumentRev(document, rev)
+	return rev, nil
 }
 
-// Delete removed a document from the Database.
-func (d *Database) Delete(document Document) (string, error) {
-	docMeta := document.Metadata()
+// Delete removed a document from the Database. As with Put, the revision of the deletion
+// is stored in documents which use struct tags to mark their revision field.
+func (d *Database) Delete(document interface{}) (string, error) {
+	docMeta, err := documentMetadata(document)
+	if err != nil {
+		return "", err
+	}
 	path := d.DocumentPath(docMeta.ID)
 
 	var opts = NewURLOptions()
@@ -94,7 +115,13 @@ func (d *Database) Delete(document Document) (string, error) {
 		return "", err
 	}
 
-	return responseEtag(resp)
+	rev, err := responseEtag(resp)
+	if err != nil {
+		return "", err
+	}
+
+	setDocumentRev(document, rev)
+	return rev, nil
 }
 
 // Name returns the name of the Database.
@@ -225,3 +252,12 @@ func (d *Database) ContinuousChangesFeed(params ChangesFeedParams) ContinuousCha
 		params: params,
 	}
 }
+
+// EventSourceChangesFeed gets a changes feed which receives changes over a continuous
+// connection to the database in the text/event-stream format.
+func (d *Database) EventSourceChangesFeed(params ChangesFeedParams) EventSourceChangesFeed {
+	return EventSourceChangesFeed{
+		db:     d,
+		params: params,
+	}
+}
@@ -168,3 +168,85 @@ func TestDatabaseReal3(t *testing.T) {
 
 	cleanTestDB(t, con.Connection, "test_db", false)
 }
+
+type taggedTestDoc struct {
+	ID      string `json:"_id" couch:"_id"`
+	Rev     string `json:"_rev,omitempty" couch:"_rev"`
+	Content string `json:"content"`
+}
+
+func TestDatabasePutTagged(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version1MockHost)).
+		Put("/test_db/newdoc").
+		MatchParam("rev", DefaultFirstRev).
+		BodyString(fmt.Sprintf(`{"_id":"newdoc","_rev":"%s","content":"Tagged"}`, DefaultFirstRev)).
+		Reply(201).
+		JSON(map[string]interface{}{
+			"ok":  true,
+			"id":  "newdoc",
+			"rev": DefaultSecondRev,
+		}).
+		SetHeader("Etag", fmt.Sprintf(`"%s"`, DefaultSecondRev))
+
+	con := globalTestConnections.Version1(t, true)
+	db := con.Database("test_db")
+
+	doc := &taggedTestDoc{
+		ID:      "newdoc",
+		Rev:     DefaultFirstRev,
+		Content: "Tagged",
+	}
+
+	rev, err := db.Put(doc)
+	st.Assert(t, err, nil)
+
+	st.Assert(t, rev, DefaultSecondRev)
+	st.Assert(t, doc.Rev, DefaultSecondRev)
+}
+
+func TestDatabaseDeleteTagged(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version1MockHost)).
+		Delete("/test_db/newdoc").
+		MatchParam("rev", DefaultSecondRev).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"ok":  true,
+			"id":  "newdoc",
+			"rev": DefaultThirdRev,
+		}).
+		SetHeader("Etag", fmt.Sprintf(`"%s"`, DefaultThirdRev))
+
+	con := globalTestConnections.Version1(t, true)
+	db := con.Database("test_db")
+
+	doc := &taggedTestDoc{
+		ID:  "newdoc",
+		Rev: DefaultSecondRev,
+	}
+
+	rev, err := db.Delete(doc)
+	st.Assert(t, err, nil)
+
+	st.Assert(t, rev, DefaultThirdRev)
+	st.Assert(t, doc.Rev, DefaultThirdRev)
+}
+
+func TestDocumentMetadataTags(t *testing.T) {
+	md, err := documentMetadata(taggedTestDoc{ID: "a", Rev: "1-a"})
+	st.Assert(t, err, nil)
+	st.Assert(t, md, DocumentMetadata{ID: "a", Rev: "1-a"})
+
+	md, err = documentMetadata(&DocumentMetadata{ID: "b"})
+	st.Assert(t, err, nil)
+	st.Assert(t, md, DocumentMetadata{ID: "b"})
+
+	_, err = documentMetadata(struct{ Name string }{"untagged"})
+	st.Reject(t, err, nil)
+
+	_, err = documentMetadata(map[string]interface{}{})
+	st.Reject(t, err, nil)
+}
@@ -0,0 +1,153 @@
+package sofa
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrFunctionDisabled is returned (wrapping the ResponseError from the server) when a
+// design document function type such as _show, _list or _update has been disabled or
+// removed on the server.
+var ErrFunctionDisabled = errors.New("design document function is disabled on this server")
+
+// FunctionResponse is the raw response from a show or list function. The content is read
+// directly from the server by reading from the FunctionResponse, which must be closed
+// after use.
+type FunctionResponse struct {
+	io.ReadCloser
+
+	// ContentType is the content type set by the function.
+	ContentType string
+	// StatusCode is the HTTP status code set by the function.
+	StatusCode int
+}
+
+// Show executes a show function from a design document, optionally passing it the document
+// with the provided ID (no document is passed if docID is empty). Show functions are
+// available in CouchDB 1.x, 2.x & 3.x.
+func (d *Database) Show(ddoc, fn, docID string, opts Options) (*FunctionResponse, error) {
+	path := urlConcat(d.DesignPath(ddoc), "_show/"+fn)
+	if docID != "" {
+		path = urlConcat(path, docID)
+	}
+
+	if opts == nil {
+		opts = NewURLOptions()
+	}
+
+	return d.functionRequest("GET", path, opts, nil, nil)
+}
+
+// List executes a list function from a design document over the results of a view. The
+// view is either the name of a view in the same design document or "otherddoc/view" for a
+// view from a different design document. List functions are available in CouchDB 1.x, 2.x
+// & 3.x.
+func (d *Database) List(ddoc, fn, view string, params ViewParams) (*FunctionResponse, error) {
+	path := urlConcat(d.DesignPath(ddoc), fmt.Sprintf("_list/%s/%s", fn, view))
+
+	params, err := params.forVersion(d.con.version)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := params.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.functionRequest("GET", path, opts, nil, nil)
+}
+
+// UpdateResponse is the result of calling an update handler.
+type UpdateResponse struct {
+	// Body is the response returned by the update handler.
+	Body []byte
+	// ContentType is the content type of the response.
+	ContentType string
+	// NewRev is the revision of the document saved by the handler (from the
+	// X-Couch-Update-NewRev header). It is empty if no document was saved.
+	NewRev string
+	// ID is the ID of the document which was updated (from the X-Couch-Id header).
+	ID string
+}
+
+// UpdateHandler calls an update handler from a design document, sending the provided body
+// to it. When docID is empty the handler is called with a POST request and no existing
+// document, otherwise it is called with a PUT request for the document with that ID. The
+// body is sent with the provided content type (such as application/x-www-form-urlencoded
+// for a handler which reads req.form), or as application/json if it is empty.
+func (d *Database) UpdateHandler(ddoc, fn, docID string, body io.Reader, contentType string, opts Options) (UpdateResponse, error) {
+	method := "POST"
+	path := urlConcat(d.DesignPath(ddoc), "_update/"+fn)
+	if docID != "" {
+		method = "PUT"
+		path = urlConcat(path, docID)
+	}
+
+	if opts == nil {
+		opts = NewURLOptions()
+	}
+
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	resp, err := d.con.headerRequest(method, path, opts, header, body)
+	if err != nil {
+		return UpdateResponse{}, functionError(err)
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return UpdateResponse{}, err
+	}
+
+	return UpdateResponse{
+		Body:        respBytes,
+		ContentType: resp.Header.Get("Content-Type"),
+		NewRev:      resp.Header.Get("X-Couch-Update-NewRev"),
+		ID:          resp.Header.Get("X-Couch-Id"),
+	}, nil
+}
+
+// DesignPath returns the path to a design document in this database. The name can be
+// given either with or without the "_design/" prefix.
+func (d *Database) DesignPath(ddoc string) string {
+	return d.DocumentPath("_design/" + strings.TrimPrefix(ddoc, "_design/"))
+}
+
+// functionRequest sends a request to a design document function without a timeout, as
+// the response is streamed to the caller.
+func (d *Database) functionRequest(method, path string, opts Options, header http.Header, body io.Reader) (*FunctionResponse, error) {
+	resp, err := d.con.urlRequest(method, d.con.URL(path), opts, header, body, false)
+	if err != nil {
+		return nil, functionError(err)
+	}
+
+	return &FunctionResponse{
+		ReadCloser: resp.Body,
+
+		ContentType: resp.Header.Get("Content-Type"),
+		StatusCode:  resp.StatusCode,
+	}, nil
+}
+
+// functionError wraps the error from the server in ErrFunctionDisabled if it indicates
+// that the type of function is not available on the server.
+func functionError(err error) error {
+	if !isResponseError(err) {
+		return err
+	}
+
+	rerr := err.(ResponseError)
+	if rerr.StatusCode == http.StatusGone || rerr.StatusCode == http.StatusNotImplemented || strings.Contains(rerr.Reason, "disabled") {
+		return fmt.Errorf("%w: %v", ErrFunctionDisabled, err)
+	}
+
+	return err
+}
@@ -0,0 +1,179 @@
+package sofa
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// stagingDesignSuffix is added to the name of a design document, followed by a random
+// string, to get the name used to build its indexes during DeployDesign.
+const stagingDesignSuffix = "-staging-"
+
+// DeployDesign replaces a design document without making queries wait while its views are
+// rebuilt. The new design document is first saved under a unique staging name (the name
+// followed by "-staging-" and a random string, so that concurrent deployments do not
+// interfere) and WaitIndexed is used to wait for its indexes to be built. It is then copied
+// over the live design document: as the views are identical CouchDB reuses the index which
+// has already been built, so the swap is instant. Finally the staging design document is
+// deleted and ViewCleanup is called to remove the old index files.
+//
+// If waiting for the indexes fails or the context is done before the swap then the staging
+// design document is deleted and the live design document is left unchanged. Once the swap
+// has been made the new revision is always returned: if the staging design document cannot
+// be deleted then a CleanupError is returned with it, and as ViewCleanup requires admin
+// access any error from it is ignored.
+//
+// The design can be any value which marshals to a JSON object containing the content of
+// the design document; any _id or _rev it contains is ignored. The design document name can
+// be given either with or without the "_design/" prefix. The new revision of the live
+// design document is returned.
+func (d *Database) DeployDesign(ctx context.Context, ddoc string, design interface{}) (string, error) {
+	name := strings.TrimPrefix(ddoc, "_design/")
+	liveID := "_design/" + name
+
+	suffix, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+	stagingID := liveID + stagingDesignSuffix + suffix
+
+	stagingRev, err := d.putDesign(stagingID, design)
+	if err != nil {
+		return "", err
+	}
+
+	liveRev, err := d.swapDesign(ctx, stagingID, liveID)
+	if err != nil {
+		// The error which stopped the deployment is more useful than any from the cleanup
+		d.deleteDocument(stagingID, stagingRev)
+		return "", err
+	}
+
+	if err := d.deleteDocument(stagingID, stagingRev); err != nil {
+		return liveRev, CleanupError{DesignDoc: stagingID, Err: err}
+	}
+
+	// Removing the old index files is best effort as it requires admin access.
+	d.ViewCleanup()
+
+	return liveRev, nil
+}
+
+// swapDesign waits for the indexes of the staging design document to be built and then
+// copies it over the live design document, returning the new revision of the live design.
+func (d *Database) swapDesign(ctx context.Context, stagingID, liveID string) (string, error) {
+	if err := d.WaitIndexed(ctx, stagingID); err != nil {
+		return "", err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return d.copyDocument(stagingID, liveID)
+}
+
+// randomHex returns a random string of n bytes encoded as hex, used to give temporary
+// design documents unique names.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// putDesign saves the content of a design document with the provided ID, overwriting any
+// existing document with that ID, and returns the new revision.
+func (d *Database) putDesign(id string, design interface{}) (string, error) {
+	b, err := json.Marshal(design)
+	if err != nil {
+		return "", err
+	}
+
+	content := map[string]interface{}{}
+	if err := json.Unmarshal(b, &content); err != nil {
+		return "", err
+	}
+
+	rev, err := d.currentRev(id)
+	if err != nil {
+		return "", err
+	}
+
+	content["_id"] = id
+	delete(content, "_rev")
+	if rev != "" {
+		content["_rev"] = rev
+	}
+
+	if b, err = json.Marshal(content); err != nil {
+		return "", err
+	}
+
+	res := ServerResponse{}
+	resp, err := d.con.unmarshalRequest("PUT", d.DocumentPath(id), NewURLOptions(), bytes.NewBuffer(b), &res)
+	if err != nil {
+		return "", err
+	}
+
+	return responseEtag(resp)
+}
+
+// copyDocument copies a document to the destination ID using a COPY request, overwriting
+// any existing document at the destination, and returns the new revision of the copy.
+func (d *Database) copyDocument(id, destination string) (string, error) {
+	rev, err := d.currentRev(destination)
+	if err != nil {
+		return "", err
+	}
+
+	if rev != "" {
+		destination += "?rev=" + rev
+	}
+
+	header := http.Header{}
+	header.Set("Destination", destination)
+
+	resp, err := d.con.headerRequest("COPY", d.DocumentPath(id), NewURLOptions(), header, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp.Body.Close()
+
+	return responseEtag(resp)
+}
+
+// deleteDocument deletes the revision of the document with the provided ID.
+func (d *Database) deleteDocument(id, rev string) error {
+	opts := NewURLOptions()
+	if err := opts.Set("rev", rev); err != nil {
+		return err
+	}
+
+	_, err := d.con.Delete(d.DocumentPath(id), opts)
+	return err
+}
+
+// currentRev gets the current revision of a document, or an empty string if the document
+// does not exist.
+func (d *Database) currentRev(id string) (string, error) {
+	resp, err := d.con.Head(d.DocumentPath(id), NewURLOptions())
+	if err != nil {
+		if ErrorStatus(err, http.StatusNotFound) {
+			return "", nil
+		}
+
+		return "", err
+	}
+	resp.Body.Close()
+
+	return responseEtag(resp)
+}
@@ -0,0 +1,218 @@
+package sofa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+const stagingDesignPath = "/deploy_test_db/_design/fruit-staging-[0-9a-f]{16}"
+
+// mockDeployStaging mocks saving the staging design document & waiting for its index.
+func mockDeployStaging(host string, views map[string]interface{}) {
+	// The staging design document does not exist yet
+	gock.New(host).
+		Head(stagingDesignPath + "$").
+		Reply(404)
+
+	gock.New(host).
+		Put(stagingDesignPath+"$").
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			var design map[string]interface{}
+			if err := json.NewDecoder(req.Body).Decode(&design); err != nil {
+				return false, err
+			}
+
+			id, _ := design["_id"].(string)
+			return strings.HasSuffix(req.URL.Path, id) && design["_rev"] == nil && design["language"] == "javascript", nil
+		}).
+		Reply(201).
+		SetHeader("Etag", `"`+DefaultFirstRev+`"`).
+		JSON(map[string]interface{}{"ok": true, "rev": DefaultFirstRev})
+
+	gock.New(host).
+		Get(stagingDesignPath + "$").
+		Reply(200).
+		JSON(map[string]interface{}{"_rev": DefaultFirstRev, "views": views})
+}
+
+func TestDeployDesign(t *testing.T) {
+	defer gock.Off()
+	defer func(interval time.Duration) { waitIndexedInterval = interval }(waitIndexedInterval)
+	waitIndexedInterval = time.Millisecond
+
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)
+	views := map[string]interface{}{"by-name": map[string]interface{}{"map": "function(doc) { emit(doc.name) }"}}
+
+	mockDeployStaging(host, views)
+
+	gock.New(host).
+		Get(stagingDesignPath+"/_view/by-name").
+		MatchParam("stale", "update_after").
+		Reply(200).
+		JSON(map[string]interface{}{"total_rows": 0, "offset": 0, "rows": []interface{}{}})
+
+	gock.New(host).
+		Get(stagingDesignPath + "/_info").
+		Reply(200).
+		JSON(map[string]interface{}{"name": "fruit-staging", "view_index": map[string]interface{}{"updater_running": false}})
+
+	gock.New(host).
+		Get("/_active_tasks").
+		Reply(200).
+		JSON([]interface{}{})
+
+	gock.New(host).
+		Get(stagingDesignPath+"/_view/by-name").
+		MatchParam("limit", "0").
+		Reply(200).
+		JSON(map[string]interface{}{"total_rows": 3, "offset": 0, "rows": []interface{}{}})
+
+	gock.New(host).
+		Head("/deploy_test_db/_design/fruit$").
+		Reply(200).
+		SetHeader("Etag", `"`+DefaultSecondRev+`"`)
+
+	copyReq := gock.New(host).Path(stagingDesignPath + "$")
+	copyReq.Method = "COPY"
+	copyReq.
+		MatchHeader("Destination", regexp.QuoteMeta("_design/fruit?rev="+DefaultSecondRev)).
+		Reply(201).
+		SetHeader("Etag", `"`+DefaultThirdRev+`"`).
+		JSON(map[string]interface{}{"ok": true, "id": "_design/fruit", "rev": DefaultThirdRev})
+
+	gock.New(host).
+		Delete(stagingDesignPath+"$").
+		MatchParam("rev", DefaultFirstRev).
+		Reply(200).
+		JSON(map[string]interface{}{"ok": true})
+
+	// Failing to remove the old index files does not fail the deployment
+	gock.New(host).
+		Post("/deploy_test_db/_view_cleanup").
+		Reply(403).
+		JSON(map[string]interface{}{"error": "unauthorized", "reason": "You are not a db or server admin."})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("deploy_test_db")
+
+	rev, err := db.DeployDesign(context.Background(), "_design/fruit", map[string]interface{}{
+		"_id":      "ignored",
+		"_rev":     "1-ignored",
+		"language": "javascript",
+		"views":    views,
+	})
+	st.Assert(t, err, nil)
+	st.Assert(t, rev, DefaultThirdRev)
+	st.Assert(t, gock.IsDone(), true)
+}
+
+func TestDeployDesignCleanupError(t *testing.T) {
+	defer gock.Off()
+	defer func(interval time.Duration) { waitIndexedInterval = interval }(waitIndexedInterval)
+	waitIndexedInterval = time.Millisecond
+
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)
+	views := map[string]interface{}{"by-name": map[string]interface{}{"map": "function(doc) { emit(doc.name) }"}}
+
+	mockDeployStaging(host, views)
+
+	gock.New(host).
+		Get(stagingDesignPath + "/_view/by-name").
+		Times(2).
+		Reply(200).
+		JSON(map[string]interface{}{"total_rows": 0, "offset": 0, "rows": []interface{}{}})
+
+	gock.New(host).
+		Get(stagingDesignPath + "/_info").
+		Reply(200).
+		JSON(map[string]interface{}{"name": "fruit-staging", "view_index": map[string]interface{}{"updater_running": false}})
+
+	gock.New(host).
+		Get("/_active_tasks").
+		Reply(200).
+		JSON([]interface{}{})
+
+	// The live design document does not exist yet
+	gock.New(host).
+		Head("/deploy_test_db/_design/fruit$").
+		Reply(404)
+
+	copyReq := gock.New(host).Path(stagingDesignPath + "$")
+	copyReq.Method = "COPY"
+	copyReq.
+		MatchHeader("Destination", "^_design/fruit$").
+		Reply(201).
+		SetHeader("Etag", `"`+DefaultFirstRev+`"`).
+		JSON(map[string]interface{}{"ok": true, "id": "_design/fruit", "rev": DefaultFirstRev})
+
+	gock.New(host).
+		Delete(stagingDesignPath + "$").
+		Reply(409).
+		JSON(map[string]interface{}{"error": "conflict", "reason": "Document update conflict."})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("deploy_test_db")
+
+	// The swap succeeded so the new revision is returned along with the cleanup error
+	rev, err := db.DeployDesign(context.Background(), "fruit", map[string]interface{}{"language": "javascript", "views": views})
+	st.Assert(t, rev, DefaultFirstRev)
+
+	var cerr CleanupError
+	st.Assert(t, errors.As(err, &cerr), true)
+	assertPrefix(t, cerr.DesignDoc, "_design/fruit-staging-")
+	st.Assert(t, ErrorStatus(cerr.Err, http.StatusConflict), true)
+	st.Assert(t, gock.IsDone(), true)
+}
+
+func TestDeployDesignCancelled(t *testing.T) {
+	defer gock.Off()
+	defer func(interval time.Duration) { waitIndexedInterval = interval }(waitIndexedInterval)
+	waitIndexedInterval = time.Millisecond
+
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)
+	views := map[string]interface{}{"by-name": map[string]interface{}{"map": "function(doc) { emit(doc.name) }"}}
+
+	mockDeployStaging(host, views)
+
+	gock.New(host).
+		Get(stagingDesignPath + "/_view/by-name").
+		Reply(200).
+		JSON(map[string]interface{}{"total_rows": 0, "offset": 0, "rows": []interface{}{}})
+
+	gock.New(host).
+		Get(stagingDesignPath + "/_info").
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{"name": "fruit-staging", "view_index": map[string]interface{}{"updater_running": true}})
+
+	// The staging design document is removed when the deployment is abandoned
+	gock.New(host).
+		Delete(stagingDesignPath+"$").
+		MatchParam("rev", DefaultFirstRev).
+		Reply(200).
+		JSON(map[string]interface{}{"ok": true})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("deploy_test_db")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	rev, err := db.DeployDesign(ctx, "fruit", map[string]interface{}{"language": "javascript", "views": views})
+	st.Assert(t, err, context.DeadlineExceeded)
+	st.Assert(t, rev, "")
+
+	for _, mock := range gock.Pending() {
+		st.Reject(t, mock.Request().Method, "DELETE")
+	}
+}
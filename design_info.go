@@ -0,0 +1,191 @@
+package sofa
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ViewIndexInfo contains information about the state of the view index for a design
+// document. The Sizes are reported by CouchDB 2+ while CouchDB 1.x reports DiskSize &
+// DataSize instead.
+type ViewIndexInfo struct {
+	CompactRunning bool         `json:"compact_running"`
+	Language       string       `json:"language"`
+	PurgeSeq       AlwaysString `json:"purge_seq"`
+	Signature      string       `json:"signature"`
+	Sizes          struct {
+		Active   int64 `json:"active"`
+		External int64 `json:"external"`
+		File     int64 `json:"file"`
+	} `json:"sizes"`
+	DiskSize       int64        `json:"disk_size"`
+	DataSize       int64        `json:"data_size"`
+	UpdateSeq      AlwaysString `json:"update_seq"`
+	UpdaterRunning bool         `json:"updater_running"`
+	WaitingClients int          `json:"waiting_clients"`
+	WaitingCommit  bool         `json:"waiting_commit"`
+}
+
+// DesignInfo contains information about a design document & its view index.
+type DesignInfo struct {
+	Name      string        `json:"name"`
+	ViewIndex ViewIndexInfo `json:"view_index"`
+}
+
+// DesignInfo gets information about the view index of a design document using _info. The
+// design document can be given either with or without the "_design/" prefix.
+func (d *Database) DesignInfo(ddoc string) (DesignInfo, error) {
+	var info DesignInfo
+	if _, err := d.con.unmarshalRequest("GET", urlConcat(d.DesignPath(ddoc), "_info"), NewURLOptions(), nil, &info); err != nil {
+		return DesignInfo{}, err
+	}
+
+	return info, nil
+}
+
+// waitIndexedInterval is the time between checks of the index status in WaitIndexed.
+var waitIndexedInterval = time.Second
+
+// WaitIndexed waits until the view index of a design document has been brought up to date
+// with the database, or until the context is done. The index build is started with a query
+// using stale=update_after (or update=lazy on CouchDB 3+) so no request is held open while
+// the index is built. Progress
+// is then polled using DesignInfo & the indexer tasks from Connection.ActiveTasks (if the
+// connection has admin access) until the index is idle, after which a final query which
+// only returns once the index is up to date confirms that it has caught up.
+func (d *Database) WaitIndexed(ctx context.Context, ddoc string) error {
+	view, err := d.firstViewName(ddoc)
+	if err != nil || view == "" {
+		return err
+	}
+
+	path := d.ViewPath(d.NamedView(strings.TrimPrefix(ddoc, "_design/"), view).Path())
+
+	// stale=update_after is sent as stable=true&update=lazy to CouchDB 3+
+	params, err := ViewParams{Stale: "update_after"}.forVersion(d.con.version)
+	if err != nil {
+		return err
+	}
+
+	trigger, err := params.Values()
+	if err != nil {
+		return err
+	}
+	trigger.Set("limit", "0")
+
+	resp, err := d.con.Get(path, trigger)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	ticker := time.NewTicker(waitIndexedInterval)
+	defer ticker.Stop()
+
+	for idle := false; !idle; {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if idle, err = d.indexIdle(ddoc); err != nil {
+			return err
+		}
+	}
+
+	opts := NewURLOptions()
+	if err := opts.Set("limit", 0); err != nil {
+		return err
+	}
+
+	req, err := d.con.newURLRequest("GET", d.con.URL(path), opts, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err = d.con.do(req.WithContext(ctx), false)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// indexIdle checks whether the view index of a design document is currently being
+// updated, either by the updater reported by DesignInfo or by an indexer task.
+func (d *Database) indexIdle(ddoc string) (bool, error) {
+	info, err := d.DesignInfo(ddoc)
+	if err != nil {
+		return false, err
+	}
+
+	if info.ViewIndex.UpdaterRunning || info.ViewIndex.WaitingClients > 0 {
+		return false, nil
+	}
+
+	tasks, err := d.con.ActiveTasks()
+	if err != nil {
+		// Active tasks are only available to admins so fall back on DesignInfo alone.
+		if ErrorStatus(err, http.StatusUnauthorized) || ErrorStatus(err, http.StatusForbidden) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	designID := "_design/" + strings.TrimPrefix(ddoc, "_design/")
+	for _, task := range tasks {
+		if task.Type == "indexer" && task.DesignDocument == designID && taskDatabaseName(task.Database) == d.Name() {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// firstViewName gets the name of the first view (in sorted order) defined in a design
+// document, or an empty string if the design document has no views.
+func (d *Database) firstViewName(ddoc string) (string, error) {
+	var design struct {
+		Views map[string]interface{} `json:"views"`
+	}
+
+	if _, err := d.con.unmarshalRequest("GET", d.DesignPath(ddoc), NewURLOptions(), nil, &design); err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(design.Views))
+	for name := range design.Views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return "", nil
+	}
+
+	return names[0], nil
+}
+
+// taskDatabaseName gets the database name from the database of a task. CouchDB 2+ reports
+// the path of a shard such as "shards/00000000-7fffffff/dbname.1554252384" while CouchDB
+// 1.x reports the database name itself.
+func taskDatabaseName(db string) string {
+	if !strings.HasPrefix(db, "shards/") {
+		return db
+	}
+
+	db = strings.TrimPrefix(db, "shards/")
+	if i := strings.Index(db, "/"); i >= 0 {
+		db = db[i+1:]
+	}
+	if i := strings.LastIndex(db, "."); i >= 0 {
+		db = db[:i]
+	}
+
+	return db
+}
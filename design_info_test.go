@@ -0,0 +1,188 @@
+package sofa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func TestDesignInfo(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/design_test_db/_design/fruit/_info").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"name": "fruit",
+			"view_index": map[string]interface{}{
+				"compact_running": false,
+				"language":        "javascript",
+				"purge_seq":       0,
+				"signature":       "a2d41fc1f6ac5c0c5fcf3ca5e2aa1c91",
+				"sizes":           map[string]interface{}{"active": 1024, "external": 512, "file": 4096},
+				"update_seq":      42,
+				"updater_running": true,
+				"waiting_clients": 1,
+				"waiting_commit":  false,
+			},
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("design_test_db")
+
+	info, err := db.DesignInfo("_design/fruit")
+	st.Assert(t, err, nil)
+	st.Assert(t, info.Name, "fruit")
+	st.Assert(t, info.ViewIndex.Sizes.File, int64(4096))
+	st.Assert(t, info.ViewIndex.UpdateSeq, AlwaysString("42"))
+	st.Assert(t, info.ViewIndex.UpdaterRunning, true)
+	st.Assert(t, info.ViewIndex.WaitingClients, 1)
+}
+
+func mockWaitIndexedDesign(host string) {
+	gock.New(fmt.Sprintf("https://%s", host)).
+		Get("/design_test_db/_design/fruit").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"_id":   "_design/fruit",
+			"_rev":  DefaultFirstRev,
+			"views": map[string]interface{}{"by-name": map[string]interface{}{"map": "function(doc) { emit(doc.name) }"}},
+		})
+
+	gock.New(fmt.Sprintf("https://%s", host)).
+		Get("/design_test_db/_design/fruit/_view/by-name").
+		MatchParam("stale", "update_after").
+		MatchParam("limit", "0").
+		Reply(200).
+		JSON(map[string]interface{}{"total_rows": 0, "offset": 0, "rows": []interface{}{}})
+}
+
+func TestWaitIndexed(t *testing.T) {
+	defer gock.Off()
+	defer func(interval time.Duration) { waitIndexedInterval = interval }(waitIndexedInterval)
+	waitIndexedInterval = time.Millisecond
+
+	host := globalTestConnections.Version2MockHost
+	mockWaitIndexedDesign(host)
+
+	gock.New(fmt.Sprintf("https://%s", host)).
+		Get("/design_test_db/_design/fruit/_info").
+		Reply(200).
+		JSON(map[string]interface{}{"name": "fruit", "view_index": map[string]interface{}{"updater_running": true}})
+
+	gock.New(fmt.Sprintf("https://%s", host)).
+		Get("/design_test_db/_design/fruit/_info").
+		Reply(200).
+		JSON(map[string]interface{}{"name": "fruit", "view_index": map[string]interface{}{"updater_running": false}})
+
+	gock.New(fmt.Sprintf("https://%s", host)).
+		Get("/_active_tasks").
+		Reply(200).
+		JSON([]map[string]interface{}{
+			{"type": "indexer", "database": "shards/00000000-7fffffff/design_test_db.1554252384", "design_document": "_design/fruit"},
+		})
+
+	gock.New(fmt.Sprintf("https://%s", host)).
+		Get("/design_test_db/_design/fruit/_info").
+		Reply(200).
+		JSON(map[string]interface{}{"name": "fruit", "view_index": map[string]interface{}{"updater_running": false}})
+
+	gock.New(fmt.Sprintf("https://%s", host)).
+		Get("/_active_tasks").
+		Reply(200).
+		JSON([]map[string]interface{}{
+			{"type": "indexer", "database": "shards/00000000-7fffffff/other_db.1554252384", "design_document": "_design/fruit"},
+			{"type": "database_compaction", "database": "shards/00000000-7fffffff/design_test_db.1554252384"},
+		})
+
+	gock.New(fmt.Sprintf("https://%s", host)).
+		Get("/design_test_db/_design/fruit/_view/by-name").
+		MatchParam("limit", "0").
+		Reply(200).
+		JSON(map[string]interface{}{"total_rows": 3, "offset": 0, "rows": []interface{}{}})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("design_test_db")
+
+	st.Assert(t, db.WaitIndexed(context.Background(), "fruit"), nil)
+	st.Assert(t, gock.IsDone(), true)
+}
+
+func TestWaitIndexedVersion3(t *testing.T) {
+	defer gock.Off()
+	defer func(interval time.Duration) { waitIndexedInterval = interval }(waitIndexedInterval)
+	waitIndexedInterval = time.Millisecond
+
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)
+
+	gock.New(host).
+		Get("/design_test_db/_design/fruit").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"_id":   "_design/fruit",
+			"_rev":  DefaultFirstRev,
+			"views": map[string]interface{}{"by-name": map[string]interface{}{"map": "function(doc) { emit(doc.name) }"}},
+		})
+
+	// The deprecated stale parameter is not sent to CouchDB 3
+	gock.New(host).
+		Get("/design_test_db/_design/fruit/_view/by-name").
+		MatchParam("stable", "^true$").
+		MatchParam("update", "^lazy$").
+		MatchParam("limit", "^0$").
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			return req.URL.Query().Get("stale") == "", nil
+		}).
+		Reply(200).
+		JSON(map[string]interface{}{"total_rows": 0, "offset": 0, "rows": []interface{}{}})
+
+	gock.New(host).
+		Get("/design_test_db/_design/fruit/_info").
+		Reply(200).
+		JSON(map[string]interface{}{"name": "fruit", "view_index": map[string]interface{}{"updater_running": false}})
+
+	gock.New(host).
+		Get("/_active_tasks").
+		Reply(200).
+		JSON([]map[string]interface{}{})
+
+	gock.New(host).
+		Get("/design_test_db/_design/fruit/_view/by-name").
+		MatchParam("limit", "^0$").
+		Reply(200).
+		JSON(map[string]interface{}{"total_rows": 3, "offset": 0, "rows": []interface{}{}})
+
+	con := globalTestConnections.Version3(t, true)
+	db := con.Database("design_test_db")
+
+	st.Assert(t, db.WaitIndexed(context.Background(), "fruit"), nil)
+	st.Assert(t, gock.IsDone(), true)
+}
+
+func TestWaitIndexedCancelled(t *testing.T) {
+	defer gock.Off()
+	defer func(interval time.Duration) { waitIndexedInterval = interval }(waitIndexedInterval)
+	waitIndexedInterval = time.Millisecond
+
+	host := globalTestConnections.Version2MockHost
+	mockWaitIndexedDesign(host)
+
+	gock.New(fmt.Sprintf("https://%s", host)).
+		Get("/design_test_db/_design/fruit/_info").
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{"name": "fruit", "view_index": map[string]interface{}{"updater_running": true}})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("design_test_db")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	st.Assert(t, db.WaitIndexed(ctx, "fruit"), context.DeadlineExceeded)
+}
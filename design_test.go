@@ -0,0 +1,171 @@
+package sofa
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func TestShow(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/design_test_db/_design/things/_show/summary/fruit1").
+		MatchParam("format", "short").
+		Reply(200).
+		BodyString("<p>apple</p>").
+		SetHeader("Content-Type", "text/html; charset=utf-8")
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("design_test_db")
+
+	opts := NewURLOptions()
+	st.Assert(t, opts.Set("format", "short"), nil)
+
+	resp, err := db.Show("things", "summary", "fruit1", opts)
+	st.Assert(t, err, nil)
+	defer resp.Close()
+
+	content, err := io.ReadAll(resp)
+	st.Assert(t, err, nil)
+
+	st.Assert(t, string(content), "<p>apple</p>")
+	st.Assert(t, resp.ContentType, "text/html; charset=utf-8")
+	st.Assert(t, resp.StatusCode, 200)
+}
+
+func TestShowDisabled(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Get("/design_test_db/_design/things/_show/summary").
+		Reply(410).
+		JSON(map[string]string{"error": "gone", "reason": "show functions are not supported"})
+
+	con := globalTestConnections.Version3(t, true)
+	db := con.Database("design_test_db")
+
+	_, err := db.Show("_design/things", "summary", "", nil)
+	st.Assert(t, errors.Is(err, ErrFunctionDisabled), true)
+}
+
+func TestList(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version1MockHost)).
+		Get("/design_test_db/_design/things/_list/csv/other/byType").
+		MatchParam("descending", "true").
+		Reply(200).
+		BodyString("fruit,apple\n").
+		SetHeader("Content-Type", "text/csv")
+
+	con := globalTestConnections.Version1(t, true)
+	db := con.Database("design_test_db")
+
+	resp, err := db.List("things", "csv", "other/byType", ViewParams{Descending: True})
+	st.Assert(t, err, nil)
+	defer resp.Close()
+
+	content, err := io.ReadAll(resp)
+	st.Assert(t, err, nil)
+
+	st.Assert(t, string(content), "fruit,apple\n")
+	st.Assert(t, resp.ContentType, "text/csv")
+}
+
+func TestListMissing(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version1MockHost)).
+		Get("/design_test_db/_design/things/_list/csv/byType").
+		Reply(404).
+		JSON(map[string]string{"error": "not_found", "reason": "missing list function csv on design doc _design/things"})
+
+	con := globalTestConnections.Version1(t, true)
+	db := con.Database("design_test_db")
+
+	_, err := db.List("things", "csv", "byType", ViewParams{})
+	st.Assert(t, ErrorStatus(err, 404), true)
+	st.Assert(t, errors.Is(err, ErrFunctionDisabled), false)
+}
+
+func TestUpdateHandlerPut(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Put("/design_test_db/_design/things/_update/bump/fruit1").
+		MatchParam("field", "count").
+		MatchHeader("Content-Type", "application/json").
+		BodyString(`{"by":2}`).
+		Reply(201).
+		BodyString("bumped").
+		SetHeader("Content-Type", "text/plain").
+		SetHeader("X-Couch-Update-NewRev", DefaultSecondRev).
+		SetHeader("X-Couch-Id", "fruit1")
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("design_test_db")
+
+	opts := NewURLOptions()
+	st.Assert(t, opts.Set("field", "count"), nil)
+
+	resp, err := db.UpdateHandler("things", "bump", "fruit1", strings.NewReader(`{"by":2}`), "", opts)
+	st.Assert(t, err, nil)
+
+	st.Assert(t, string(resp.Body), "bumped")
+	st.Assert(t, resp.ContentType, "text/plain")
+	st.Assert(t, resp.NewRev, DefaultSecondRev)
+	st.Assert(t, resp.ID, "fruit1")
+}
+
+func TestUpdateHandlerForm(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Post("/design_test_db/_design/things/_update/create").
+		MatchHeader("Content-Type", "^application/x-www-form-urlencoded$").
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			b, err := io.ReadAll(req.Body)
+			return string(b) == "name=apple&type=fruit", err
+		}).
+		Reply(201).
+		BodyString("created").
+		SetHeader("X-Couch-Id", "a1b2c3")
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("design_test_db")
+
+	form := url.Values{"name": {"apple"}, "type": {"fruit"}}
+	resp, err := db.UpdateHandler("things", "create", "", strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil)
+	st.Assert(t, err, nil)
+
+	st.Assert(t, string(resp.Body), "created")
+	st.Assert(t, resp.ID, "a1b2c3")
+}
+
+func TestUpdateHandlerPost(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Post("/design_test_db/_design/things/_update/create").
+		Reply(201).
+		JSON(map[string]string{"created": "yes"}).
+		SetHeader("X-Couch-Update-NewRev", DefaultFirstRev).
+		SetHeader("X-Couch-Id", "a1b2c3")
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("design_test_db")
+
+	resp, err := db.UpdateHandler("things", "create", "", nil, "", nil)
+	st.Assert(t, err, nil)
+
+	st.Assert(t, resp.NewRev, DefaultFirstRev)
+	st.Assert(t, resp.ID, "a1b2c3")
+}
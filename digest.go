@@ -0,0 +1,158 @@
+package sofa
+
+import (
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+)
+
+// md5Digest formats the current value of a MD5 hash in the same way as the digests included
+// in attachment stubs.
+func md5Digest(h hash.Hash) string {
+	return "md5-" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// attachmentBody prepares the body of an attachment response to be read. When CouchDB includes
+// a Content-MD5 header the content is hashed as it is read and a DigestError is returned in
+// place of io.EOF if it does not match. Content which the server sent compressed is also
+// decompressed after verification.
+func attachmentBody(resp *http.Response, name string) (io.ReadCloser, error) {
+	body := resp.Body
+
+	// Partial responses never include a Content-MD5 header but make sure that a digest
+	// of the whole attachment is not checked against part of it.
+	if expected := resp.Header.Get("Content-MD5"); expected != "" && resp.StatusCode == http.StatusOK {
+		body = &digestReader{
+			ReadCloser: body,
+
+			name:     name,
+			expected: "md5-" + expected,
+			hash:     md5.New(),
+		}
+	}
+
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+
+		return &gzipReadCloser{Reader: gz, body: body}, nil
+	}
+
+	return body, nil
+}
+
+// digestReader hashes everything read from the wrapped io.ReadCloser and checks the
+// result against the expected digest when the end of the content is reached.
+type digestReader struct {
+	io.ReadCloser
+
+	name     string
+	expected string
+	hash     hash.Hash
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+
+	if err == io.EOF {
+		if actual := md5Digest(r.hash); actual != r.expected {
+			return n, DigestError{
+				Name:     r.name,
+				Expected: r.expected,
+				Actual:   actual,
+			}
+		}
+	}
+
+	return n, err
+}
+
+// gzipReadCloser decompresses a response body and closes the underlying body when closed.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (r *gzipReadCloser) Close() error {
+	r.Reader.Close()
+	return r.body.Close()
+}
+
+// seekableReader returns the reader as an io.ReadSeeker if seeking it actually works. Files
+// which are not regular files (such as pipes & os.Stdin) implement io.Seeker but cannot be
+// seeked, so they are treated as plain readers.
+func seekableReader(r io.Reader) (io.ReadSeeker, bool) {
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		return nil, false
+	}
+
+	if f, ok := r.(*os.File); ok {
+		fi, err := f.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return nil, false
+		}
+	}
+
+	if _, err := rs.Seek(0, io.SeekCurrent); err != nil {
+		return nil, false
+	}
+
+	return rs, true
+}
+
+// seekerMD5 calculates the Content-MD5 header value for the remaining content of an
+// io.ReadSeeker and then returns it to the original position so it can be sent.
+func seekerMD5(rs io.ReadSeeker) (string, error) {
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	h := md5.New()
+	if _, err := io.Copy(h, rs); err != nil {
+		return "", err
+	}
+
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// md5TrailerReader hashes content as it is sent to the server and fills in a Content-MD5
+// trailer once all of the content has been read. This allows a digest to be sent with
+// content which can only be read once.
+type md5TrailerReader struct {
+	r       io.Reader
+	hash    hash.Hash
+	trailer http.Header
+}
+
+func newMD5TrailerReader(r io.Reader) *md5TrailerReader {
+	return &md5TrailerReader{
+		r:       r,
+		hash:    md5.New(),
+		trailer: http.Header{"Content-Md5": nil},
+	}
+}
+
+func (r *md5TrailerReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.hash.Write(p[:n])
+
+	if err == io.EOF {
+		r.trailer.Set("Content-MD5", base64.StdEncoding.EncodeToString(r.hash.Sum(nil)))
+	}
+
+	return n, err
+}
@@ -0,0 +1,209 @@
+package sofa
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+const AttachmentContentMD5 = "1rm+KhuliTR07qnK1N0A/A=="
+
+func TestGetAttachmentDigest(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/attachment_test_db/fruit1/test.txt").
+		MatchHeader("Accept-Encoding", "^gzip$").
+		Reply(200).
+		BodyString(AttachmentContent).
+		SetHeader("Content-MD5", AttachmentContentMD5)
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	content, err := db.GetAttachment("fruit1", AttachmentFileName, "")
+	st.Assert(t, err, nil)
+	st.Assert(t, string(content), AttachmentContent)
+}
+
+func TestGetAttachmentDigestMismatch(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/attachment_test_db/fruit1/test.txt").
+		Reply(200).
+		BodyString("Hello Couchy McCorruptface").
+		SetHeader("Content-MD5", AttachmentContentMD5)
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	_, err := db.GetAttachment("fruit1", AttachmentFileName, "")
+
+	digestErr, ok := err.(DigestError)
+	if !ok {
+		t.Fatalf("expected a DigestError but got: %v", err)
+	}
+
+	st.Assert(t, digestErr.Name, AttachmentFileName)
+	st.Assert(t, digestErr.Expected, "md5-"+AttachmentContentMD5)
+}
+
+func TestGetAttachmentCompressed(t *testing.T) {
+	defer gock.Off()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(AttachmentContent))
+	st.Assert(t, err, nil)
+	st.Assert(t, gz.Close(), nil)
+
+	compressed := buf.Bytes()
+	sum, err := seekerMD5(bytes.NewReader(compressed))
+	st.Assert(t, err, nil)
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/attachment_test_db/fruit1/test.txt").
+		Reply(200).
+		Body(bytes.NewReader(compressed)).
+		SetHeader("Content-Encoding", "gzip").
+		SetHeader("Content-MD5", sum)
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	content, err := db.GetAttachment("fruit1", AttachmentFileName, "")
+	st.Assert(t, err, nil)
+	st.Assert(t, string(content), AttachmentContent)
+}
+
+func TestPutAttachmentContentMD5(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Put("/attachment_test_db/fruit1/test.txt").
+		MatchHeader("Content-MD5", "^"+regexp.QuoteMeta(AttachmentContentMD5)+"$").
+		BodyString(AttachmentContent).
+		Reply(201).
+		JSON(map[string]interface{}{
+			"ok":  true,
+			"id":  "fruit1",
+			"rev": DefaultSecondRev,
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	rev, err := db.PutAttachment("fruit1", AttachmentFileName, strings.NewReader(AttachmentContent), DefaultFirstRev)
+	st.Assert(t, err, nil)
+	st.Assert(t, rev, DefaultSecondRev)
+}
+
+func TestPutAttachmentContentMD5Trailer(t *testing.T) {
+	defer gock.Off()
+
+	var trailer string
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Put("/attachment_test_db/fruit1/test.txt").
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return false, err
+			}
+
+			trailer = req.Trailer.Get("Content-MD5")
+			return string(body) == AttachmentContent, nil
+		}).
+		Reply(201).
+		JSON(map[string]interface{}{
+			"ok":  true,
+			"id":  "fruit1",
+			"rev": DefaultSecondRev,
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	// Hide the io.Seeker implementation so that the digest must be sent as a trailer.
+	content := struct{ io.Reader }{strings.NewReader(AttachmentContent)}
+
+	rev, err := db.PutAttachment("fruit1", AttachmentFileName, content, DefaultFirstRev)
+	st.Assert(t, err, nil)
+	st.Assert(t, rev, DefaultSecondRev)
+	st.Assert(t, trailer, AttachmentContentMD5)
+}
+
+func TestPutAttachmentPipe(t *testing.T) {
+	defer gock.Off()
+
+	var trailer string
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Put("/attachment_test_db/fruit1/test.txt").
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return false, err
+			}
+
+			trailer = req.Trailer.Get("Content-MD5")
+			return string(body) == AttachmentContent && req.Header.Get("Content-MD5") == "", nil
+		}).
+		Reply(201).
+		JSON(map[string]interface{}{
+			"ok":  true,
+			"id":  "fruit1",
+			"rev": DefaultSecondRev,
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	// A pipe is an *os.File & so an io.ReadSeeker, but it cannot be seeked.
+	pr, pw, err := os.Pipe()
+	st.Assert(t, err, nil)
+	defer pr.Close()
+
+	go func() {
+		pw.WriteString(AttachmentContent)
+		pw.Close()
+	}()
+
+	rev, err := db.PutAttachment("fruit1", AttachmentFileName, pr, DefaultFirstRev)
+	st.Assert(t, err, nil)
+	st.Assert(t, rev, DefaultSecondRev)
+	st.Assert(t, trailer, AttachmentContentMD5)
+}
+
+func TestPutAttachmentContentType(t *testing.T) {
+	defer gock.Off()
+
+	// PutAttachment does not guess the content type from the name
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Put("/attachment_test_db/fruit1/test.txt").
+		MatchHeader("Content-Type", "^application/json$").
+		Reply(201).
+		JSON(map[string]interface{}{
+			"ok":  true,
+			"id":  "fruit1",
+			"rev": DefaultSecondRev,
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database(AttachmentTestDB)
+
+	rev, err := db.PutAttachment("fruit1", AttachmentFileName, strings.NewReader(AttachmentContent), DefaultFirstRev)
+	st.Assert(t, err, nil)
+	st.Assert(t, rev, DefaultSecondRev)
+	st.Assert(t, gock.IsDone(), true)
+}
@@ -1,9 +1,26 @@
 package sofa
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
 
 // Document is the interface which represents a CouchDB document. Contains methods to retrieve the
 // metadata and the database containing this document.
+//
+// Types which do not implement Document can still be passed to Database.Get, Database.Put and
+// Database.Delete if they are structs marking their ID and revision fields with struct tags:
+//
+//	type Fruit struct {
+//		ID   string `json:"_id" couch:"_id"`
+//		Rev  string `json:"_rev,omitempty" couch:"_rev"`
+//		Name string `json:"name"`
+//	}
+//
+// When a pointer to such a struct is used the new revision is stored in the field tagged with
+// `couch:"_rev"` after a successful Put or Delete.
 type Document interface {
 	Metadata() DocumentMetadata
 }
@@ -47,3 +64,77 @@ func (gen *GenericDocument) MarshalJSON() ([]byte, error) {
 func (gen *GenericDocument) UnmarshalJSON(data []byte) error {
 	return json.Unmarshal(data, &gen.document)
 }
+
+// documentMetadata gets the DocumentMetadata for a document which is either a
+// Document or a struct with fields tagged `couch:"_id"` and `couch:"_rev"`.
+func documentMetadata(document interface{}) (DocumentMetadata, error) {
+	if doc, ok := document.(Document); ok {
+		return doc.Metadata(), nil
+	}
+
+	rv, err := taggedStructValue(document)
+	if err != nil {
+		return DocumentMetadata{}, err
+	}
+
+	idField, ok := taggedField(rv, "_id")
+	if !ok {
+		return DocumentMetadata{}, fmt.Errorf("document of type %s has no field tagged couch:\"_id\"", rv.Type())
+	}
+
+	md := DocumentMetadata{ID: idField.String()}
+	if revField, ok := taggedField(rv, "_rev"); ok {
+		md.Rev = revField.String()
+	}
+
+	return md, nil
+}
+
+// setDocumentRev stores a new revision in the field tagged `couch:"_rev"` of
+// the provided document. Nothing is changed for documents which implement the
+// Document interface or which cannot be modified because they were not passed
+// as a pointer.
+func setDocumentRev(document interface{}, rev string) {
+	if _, ok := document.(Document); ok {
+		return
+	}
+
+	rv, err := taggedStructValue(document)
+	if err != nil {
+		return
+	}
+
+	if revField, ok := taggedField(rv, "_rev"); ok && revField.CanSet() {
+		revField.SetString(rev)
+	}
+}
+
+// taggedStructValue dereferences any pointers to find the underlying struct value.
+func taggedStructValue(document interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(document)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return reflect.Value{}, errors.New("document must not be a nil pointer")
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("document of type %s must be a struct or implement Document", rv.Type())
+	}
+
+	return rv, nil
+}
+
+// taggedField finds the string field in a struct which has the provided couch tag.
+func taggedField(rv reflect.Value, tag string) (reflect.Value, bool) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.Tag.Get("couch") == tag && field.Type.Kind() == reflect.String {
+			return rv.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
@@ -72,3 +72,35 @@ func unmarshalResponse(resp *http.Response, res interface{}) error {
 
 	return resp.Body.Close()
 }
+
+// DigestError is returned when the content of an attachment does not match the MD5
+// digest which CouchDB holds for it.
+type DigestError struct {
+	Name     string
+	Expected string
+	Actual   string
+}
+
+// Error provides a representation of the digest error including both digests.
+func (e DigestError) Error() string {
+	return fmt.Sprintf("attachment %s: digest mismatch: expected %s but content has %s", e.Name, e.Expected, e.Actual)
+}
+
+// CleanupError is returned when an operation succeeded but a temporary design document it
+// created could not be deleted afterwards. It is returned along with the results of a
+// TemporaryView emulated on CouchDB 2+ and with the new revision from DeployDesign, which
+// are still valid. The design document may need to be removed manually.
+type CleanupError struct {
+	DesignDoc string
+	Err       error
+}
+
+// Error provides a representation of the cleanup error including the design document.
+func (e CleanupError) Error() string {
+	return fmt.Sprintf("unable to delete temporary design document %s: %v", e.DesignDoc, e.Err)
+}
+
+// Unwrap returns the error which caused the cleanup to fail.
+func (e CleanupError) Unwrap() error {
+	return e.Err
+}
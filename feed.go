@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/google/go-querystring/query"
 )
@@ -21,8 +22,8 @@ const (
 	// FeedContinuous represents the type of feed which holds open a connection to
 	// the server and receives a stream of events.
 	FeedContinuous changesFeedType = "continuous"
-	// FeedEventSource represents the CouchDB feed type of "eventsource". This type is not
-	// yet implemented in this library.
+	// FeedEventSource represents the CouchDB feed type of "eventsource", which holds open a
+	// connection to the server and receives a stream of events in the text/event-stream format.
 	FeedEventSource changesFeedType = "eventsource"
 )
 
@@ -190,7 +191,7 @@ func (f *ContinuousChangesFeed) Next() (ChangesFeedChange, error) {
 			return ChangesFeedChange{}, err
 		}
 
-		resp, err := f.db.con.urlRequest("GET", f.db.con.URL(f.db.ViewPath("_changes")), v, nil, false)
+		resp, err := f.db.con.urlRequest("GET", f.db.con.URL(f.db.ViewPath("_changes")), v, nil, nil, false)
 		if err != nil {
 			return ChangesFeedChange{}, err
 		}
@@ -217,3 +218,143 @@ func (f *ContinuousChangesFeed) Next() (ChangesFeedChange, error) {
 
 	return u, nil
 }
+
+// EventSourceChangesFeed maintains a connection to the database and receives updates as they
+// arrive in the text/event-stream format. The ID of the last event received is remembered so
+// that, if the connection is closed, the next call to Next reconnects & resumes the feed from
+// where it left off using the Last-Event-ID header.
+type EventSourceChangesFeed struct {
+	db     *Database
+	params ChangesFeedParams
+
+	lastEventID string
+
+	resp   *http.Response
+	reader *bufio.Reader
+}
+
+// LastEventID returns the ID of the last event received from the feed, which is the seq of
+// the last change.
+func (f *EventSourceChangesFeed) LastEventID() string {
+	return f.lastEventID
+}
+
+// SetLastEventID sets the ID of the last event received, so that the next connection made by
+// the feed resumes after that event. This can be used to resume a feed using an ID saved from
+// a previous EventSourceChangesFeed.
+func (f *EventSourceChangesFeed) SetLastEventID(id string) {
+	f.lastEventID = id
+}
+
+// Next gets the next available item from the changes feed. This will block until an item
+// becomes available. Heartbeats & other events without any data are skipped. If the server
+// closes the connection then io.EOF is returned and the following call to Next will open a
+// new connection which resumes after the last event received.
+func (f *EventSourceChangesFeed) Next() (ChangesFeedChange, error) {
+	if f.resp == nil {
+		if err := f.connect(); err != nil {
+			return ChangesFeedChange{}, err
+		}
+	}
+
+	for {
+		event, id, data, err := f.readEvent()
+		if err != nil {
+			f.Close()
+			return ChangesFeedChange{}, err
+		}
+
+		if id != "" {
+			f.lastEventID = id
+		}
+
+		if event == "heartbeat" || data == "" {
+			continue
+		}
+
+		var u ChangesFeedChange
+		if err := json.Unmarshal([]byte(data), &u); err != nil {
+			return ChangesFeedChange{}, err
+		}
+
+		if id == "" && u.Seq != "" {
+			f.lastEventID = string(u.Seq)
+		}
+
+		return u, nil
+	}
+}
+
+// Close closes the connection to the server, if one is open.
+func (f *EventSourceChangesFeed) Close() error {
+	if f.resp == nil {
+		return nil
+	}
+
+	err := f.resp.Body.Close()
+	f.resp = nil
+	f.reader = nil
+
+	return err
+}
+
+func (f *EventSourceChangesFeed) connect() error {
+	f.params.SetFeedType(string(FeedEventSource))
+
+	v, err := f.params.Values()
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if f.lastEventID != "" {
+		header.Set("Last-Event-ID", f.lastEventID)
+	}
+
+	resp, err := f.db.con.urlRequest("GET", f.db.con.URL(f.db.ViewPath("_changes")), v, header, nil, false)
+	if err != nil {
+		return err
+	}
+
+	f.resp = resp
+	f.reader = bufio.NewReader(f.resp.Body)
+
+	return nil
+}
+
+// readEvent reads the fields of the next event from the stream, which ends with a blank
+// line. Comments & unknown fields are ignored and multiple data fields are joined with
+// newlines. As in a browser, an incomplete event at the end of the stream is discarded.
+func (f *EventSourceChangesFeed) readEvent() (event, id, data string, err error) {
+	var dataLines []string
+
+	for {
+		line, err := f.reader.ReadString('\n')
+		if err != nil {
+			return "", "", "", err
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			return event, id, strings.Join(dataLines, "\n"), nil
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			event = value
+		case "id":
+			id = value
+		case "data":
+			dataLines = append(dataLines, value)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package sofa
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 	"testing"
 	"time"
@@ -31,6 +32,64 @@ func TestPollingFeed(t *testing.T) {
 		Post("/test_db/_changes")
 }
 
+func TestEventSourceFeed(t *testing.T) {
+	defer gock.Off()
+
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)
+	gock.New(host).
+		Get("/feed_test_db/_changes").
+		MatchParam("feed", "eventsource").
+		MatchParam("heartbeat", "1000").
+		Reply(200).
+		SetHeader("Content-Type", "text/event-stream").
+		BodyString("data: {\"seq\":\"1-a\",\"id\":\"fruit1\",\"changes\":[{\"rev\":\"1-x\"}]}\n" +
+			"id: 1-a\n\n" +
+			"event: heartbeat\ndata: \n\n" +
+			": comment\r\n" +
+			"data: {\"seq\":\"2-b\",\"id\":\"fruit1\",\"deleted\":true,\r\n" +
+			"data: \"changes\":[{\"rev\":\"2-y\"}]}\r\n" +
+			"id: 2-b\r\n\r\n" +
+			"data: {\"seq\":\"3-c\",\"id\":\"incomplete\"}\n")
+
+	gock.New(host).
+		Get("/feed_test_db/_changes").
+		MatchParam("feed", "eventsource").
+		MatchHeader("Last-Event-ID", "^2-b$").
+		Reply(200).
+		SetHeader("Content-Type", "text/event-stream").
+		BodyString("data: {\"seq\":\"3-c\",\"id\":\"fruit2\",\"changes\":[{\"rev\":\"1-z\"}]}\nid: 3-c\n\n")
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("feed_test_db")
+
+	feed := db.EventSourceChangesFeed(&ChangesFeedParams2{Heartbeat: 1000})
+
+	change, err := feed.Next()
+	st.Assert(t, err, nil)
+	st.Assert(t, change.ID, "fruit1")
+	st.Assert(t, change.Deleted, false)
+	st.Assert(t, change.Changes[0].Rev, "1-x")
+	st.Assert(t, feed.LastEventID(), "1-a")
+
+	change, err = feed.Next()
+	st.Assert(t, err, nil)
+	st.Assert(t, change.Deleted, true)
+	st.Assert(t, change.Seq, AlwaysString("2-b"))
+	st.Assert(t, feed.LastEventID(), "2-b")
+
+	// The incomplete event is discarded when the connection closes
+	_, err = feed.Next()
+	st.Assert(t, err, io.EOF)
+	st.Assert(t, feed.LastEventID(), "2-b")
+
+	change, err = feed.Next()
+	st.Assert(t, err, nil)
+	st.Assert(t, change.ID, "fruit2")
+	st.Assert(t, feed.LastEventID(), "3-c")
+	st.Assert(t, feed.Close(), nil)
+	st.Assert(t, gock.IsDone(), true)
+}
+
 func TestFeedPollingReal1(t *testing.T) {
 	con := globalTestConnections.Version1(t, false)
 
@@ -0,0 +1,316 @@
+package sofa
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Selector is a Mango selector used to choose the documents returned by a query. Selectors
+// can be written by hand or built using the functions in this package which create the
+// selector for each of the Mango operators:
+//
+//	And(
+//		Eq("type", "fruit"),
+//		Or(Gt("rating", 3), In("name", "apple", "kiwi")),
+//		ElemMatch("tags", Eq("", "organic")),
+//	)
+type Selector map[string]interface{}
+
+// Cond creates a selector which applies a single operator to a field. It can be used for
+// any operator which does not have a specific function.
+func Cond(field, operator string, value interface{}) Selector {
+	return Selector{field: map[string]interface{}{operator: value}}
+}
+
+// Eq matches documents where the field is equal to the value.
+func Eq(field string, value interface{}) Selector {
+	return Cond(field, "$eq", value)
+}
+
+// Ne matches documents where the field is not equal to the value.
+func Ne(field string, value interface{}) Selector {
+	return Cond(field, "$ne", value)
+}
+
+// Gt matches documents where the field is greater than the value.
+func Gt(field string, value interface{}) Selector {
+	return Cond(field, "$gt", value)
+}
+
+// Gte matches documents where the field is greater than or equal to the value.
+func Gte(field string, value interface{}) Selector {
+	return Cond(field, "$gte", value)
+}
+
+// Lt matches documents where the field is less than the value.
+func Lt(field string, value interface{}) Selector {
+	return Cond(field, "$lt", value)
+}
+
+// Lte matches documents where the field is less than or equal to the value.
+func Lte(field string, value interface{}) Selector {
+	return Cond(field, "$lte", value)
+}
+
+// In matches documents where the field is equal to any of the values.
+func In(field string, values ...interface{}) Selector {
+	return Cond(field, "$in", nonNilList(values))
+}
+
+// Nin matches documents where the field is not equal to any of the values.
+func Nin(field string, values ...interface{}) Selector {
+	return Cond(field, "$nin", nonNilList(values))
+}
+
+// All matches documents where the field is an array containing all of the values.
+func All(field string, values ...interface{}) Selector {
+	return Cond(field, "$all", nonNilList(values))
+}
+
+// Exists matches documents based on whether or not the field exists.
+func Exists(field string, exists bool) Selector {
+	return Cond(field, "$exists", exists)
+}
+
+// Type matches documents where the field has the provided JSON type, which is one of
+// "null", "boolean", "number", "string", "array" or "object".
+func Type(field, jsonType string) Selector {
+	return Cond(field, "$type", jsonType)
+}
+
+// Size matches documents where the field is an array of the provided length.
+func Size(field string, length int) Selector {
+	return Cond(field, "$size", length)
+}
+
+// Mod matches documents where the field is an integer which has the provided remainder
+// when divided by the divisor.
+func Mod(field string, divisor, remainder int) Selector {
+	return Cond(field, "$mod", []int{divisor, remainder})
+}
+
+// Regex matches documents where the field is a string matching the regular expression,
+// which uses Erlang regular expression syntax.
+func Regex(field, pattern string) Selector {
+	return Cond(field, "$regex", pattern)
+}
+
+// ElemMatch matches documents where the field is an array with at least one element
+// matching the selector. Use an empty field name in the selector to match the element
+// itself rather than a field of it.
+func ElemMatch(field string, selector Selector) Selector {
+	return Cond(field, "$elemMatch", selector.elementSelector())
+}
+
+// AllMatch matches documents where the field is an array with every element matching
+// the selector. Use an empty field name in the selector to match the element itself.
+func AllMatch(field string, selector Selector) Selector {
+	return Cond(field, "$allMatch", selector.elementSelector())
+}
+
+// And matches documents which match all of the selectors.
+func And(selectors ...Selector) Selector {
+	return Selector{"$and": nonNilSelectors(selectors)}
+}
+
+// Or matches documents which match any of the selectors.
+func Or(selectors ...Selector) Selector {
+	return Selector{"$or": nonNilSelectors(selectors)}
+}
+
+// Nor matches documents which match none of the selectors.
+func Nor(selectors ...Selector) Selector {
+	return Selector{"$nor": nonNilSelectors(selectors)}
+}
+
+// Not matches documents which do not match the selector.
+func Not(selector Selector) Selector {
+	return Selector{"$not": selector}
+}
+
+// elementSelector converts conditions on the empty field name, used to refer to the array
+// element itself, into the form expected by $elemMatch and $allMatch.
+func (s Selector) elementSelector() Selector {
+	cond, ok := s[""]
+	if !ok {
+		return s
+	}
+
+	out := Selector{}
+	for k, v := range s {
+		if k != "" {
+			out[k] = v
+		}
+	}
+
+	if ops, ok := cond.(map[string]interface{}); ok {
+		for op, v := range ops {
+			out[op] = v
+		}
+	}
+
+	return out
+}
+
+func nonNilList(values []interface{}) []interface{} {
+	if values == nil {
+		return []interface{}{}
+	}
+
+	return values
+}
+
+func nonNilSelectors(selectors []Selector) []Selector {
+	if selectors == nil {
+		return []Selector{}
+	}
+
+	return selectors
+}
+
+// SortField is a single field used to sort the results of a Mango query.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// Asc sorts the results of a Mango query by the field in ascending order.
+func Asc(field string) SortField {
+	return SortField{Field: field}
+}
+
+// Desc sorts the results of a Mango query by the field in descending order.
+func Desc(field string) SortField {
+	return SortField{Field: field, Descending: true}
+}
+
+// MarshalJSON implements json.Marshaler to produce the {"field": "asc"} form used by
+// CouchDB.
+func (s SortField) MarshalJSON() ([]byte, error) {
+	direction := "asc"
+	if s.Descending {
+		direction = "desc"
+	}
+
+	return json.Marshal(map[string]string{s.Field: direction})
+}
+
+// FindQuery is a Mango query which can be run using Database.Find:
+//   - Selector chooses the documents to return. A nil Selector matches all documents.
+//   - Fields limits the fields included in each document returned.
+//   - Sort orders the results. An index including all of the fields must exist.
+//   - Limit & Skip page through the results, although using Bookmark is more efficient.
+//   - UseIndex is the design document (and optionally the name) of the index to use.
+//   - Conflicts includes conflict information in the returned documents.
+//   - Stable requests results from a single replica of each shard.
+//   - ExecutionStats includes statistics about the query in the result.
+//   - Bookmark is the bookmark returned from a previous query, used to get the next page
+//     of results.
+type FindQuery struct {
+	Selector       Selector
+	Fields         []string
+	Sort           []SortField
+	Limit          int
+	Skip           int
+	UseIndex       []string
+	Conflicts      bool
+	Stable         bool
+	ExecutionStats bool
+	Bookmark       string
+}
+
+// MarshalJSON implements json.Marshaler to produce the request body for _find.
+func (q FindQuery) MarshalJSON() ([]byte, error) {
+	body := map[string]interface{}{
+		"selector": q.Selector,
+	}
+
+	if q.Selector == nil {
+		body["selector"] = Selector{}
+	}
+
+	if len(q.Fields) > 0 {
+		body["fields"] = q.Fields
+	}
+	if len(q.Sort) > 0 {
+		body["sort"] = q.Sort
+	}
+	if q.Limit > 0 {
+		body["limit"] = q.Limit
+	}
+	if q.Skip > 0 {
+		body["skip"] = q.Skip
+	}
+	switch len(q.UseIndex) {
+	case 0:
+	case 1:
+		body["use_index"] = q.UseIndex[0]
+	default:
+		body["use_index"] = q.UseIndex
+	}
+	if q.Conflicts {
+		body["conflicts"] = true
+	}
+	if q.Stable {
+		body["stable"] = true
+	}
+	if q.ExecutionStats {
+		body["execution_stats"] = true
+	}
+	if q.Bookmark != "" {
+		body["bookmark"] = q.Bookmark
+	}
+
+	return json.Marshal(body)
+}
+
+// ExecutionStats contains the statistics returned for a Mango query when
+// FindQuery.ExecutionStats is set.
+type ExecutionStats struct {
+	TotalKeysExamined       int64   `json:"total_keys_examined"`
+	TotalDocsExamined       int64   `json:"total_docs_examined"`
+	TotalQuorumDocsExamined int64   `json:"total_quorum_docs_examined"`
+	ResultsReturned         int64   `json:"results_returned"`
+	ExecutionTimeMs         float64 `json:"execution_time_ms"`
+}
+
+// FindResult is the result of a Mango query.
+type FindResult struct {
+	Docs           []json.RawMessage `json:"docs"`
+	Warning        string            `json:"warning,omitempty"`
+	Bookmark       string            `json:"bookmark,omitempty"`
+	ExecutionStats *ExecutionStats   `json:"execution_stats,omitempty"`
+}
+
+// UnmarshalDocuments unmarshals the documents returned by the query into the provided
+// value, which should be a pointer to a slice.
+func (r FindResult) UnmarshalDocuments(docs interface{}) error {
+	b, err := json.Marshal(r.Docs)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, docs)
+}
+
+// Find runs a Mango query against the database (CouchDB 2+). To page through the results
+// set the Bookmark of the query to the Bookmark from the previous FindResult until fewer
+// documents than the Limit are returned.
+func (d *Database) Find(query FindQuery) (FindResult, error) {
+	return d.find(d.Path(), query)
+}
+
+// find runs a Mango query using the _find endpoint under the provided path.
+func (d *Database) find(path string, query FindQuery) (FindResult, error) {
+	b, err := json.Marshal(query)
+	if err != nil {
+		return FindResult{}, err
+	}
+
+	var res FindResult
+	if _, err := d.con.unmarshalRequest("POST", urlConcat(path, "_find"), NewURLOptions(), bytes.NewBuffer(b), &res); err != nil {
+		return FindResult{}, err
+	}
+
+	return res, nil
+}
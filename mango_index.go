@@ -0,0 +1,207 @@
+package sofa
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// IndexTypeJSON is the type of Mango index built from a view.
+	IndexTypeJSON = "json"
+	// IndexTypeText is the type of Mango index built using the full-text search service.
+	IndexTypeText = "text"
+	// IndexTypeSpecial is the type reported for the built-in _all_docs index, which is
+	// used when no other index can satisfy a query.
+	IndexTypeSpecial = "special"
+)
+
+// IndexField is a single field of a Mango index. For json indexes Type is either empty or
+// the sort direction ("asc" or "desc"). For text indexes Type is the type of the field
+// ("string", "number" or "boolean").
+type IndexField struct {
+	Name string
+	Type string
+}
+
+// MarshalJSON implements json.Marshaler to produce the different forms used by CouchDB
+// for index fields.
+func (f IndexField) MarshalJSON() ([]byte, error) {
+	switch f.Type {
+	case "":
+		return json.Marshal(f.Name)
+	case "asc", "desc":
+		return json.Marshal(map[string]string{f.Name: f.Type})
+	}
+
+	return json.Marshal(map[string]string{"name": f.Name, "type": f.Type})
+}
+
+// UnmarshalJSON implements json.Unmarshaler to read any of the forms used by CouchDB for
+// index fields.
+func (f *IndexField) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		*f = IndexField{}
+		return json.Unmarshal(b, &f.Name)
+	}
+
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	if name, ok := m["name"]; ok && len(m) == 2 {
+		*f = IndexField{Name: name, Type: m["type"]}
+		return nil
+	}
+
+	if len(m) != 1 {
+		return fmt.Errorf("invalid index field: %s", b)
+	}
+
+	for name, direction := range m {
+		*f = IndexField{Name: name, Type: direction}
+	}
+
+	return nil
+}
+
+// IndexDefinition describes the content of a Mango index. DefaultField, Selector, Analyzer
+// and IndexArrayLengths are only used by text indexes.
+type IndexDefinition struct {
+	Fields                []IndexField `json:"fields"`
+	PartialFilterSelector Selector     `json:"partial_filter_selector,omitempty"`
+
+	DefaultField      interface{} `json:"default_field,omitempty"`
+	Selector          Selector    `json:"selector,omitempty"`
+	Analyzer          interface{} `json:"analyzer,omitempty"`
+	IndexArrayLengths *bool       `json:"index_array_lengths,omitempty"`
+}
+
+// Index is a Mango index in a database. When creating an index the DesignDoc & Name are
+// optional and will be generated by CouchDB if they are not provided. Partitioned can be
+// set to create a global index in a partitioned database.
+type Index struct {
+	DesignDoc   string          `json:"ddoc,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	Type        string          `json:"type,omitempty"`
+	Partitioned *bool           `json:"partitioned,omitempty"`
+	Definition  IndexDefinition `json:"def"`
+}
+
+// IndexResult is the response from CouchDB when creating an index.
+type IndexResult struct {
+	// Result is either "created" or "exists".
+	Result string `json:"result"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+}
+
+// CreateIndex creates a new Mango index in the database (CouchDB 2+). If an identical
+// index already exists then the Result will be "exists" and nothing is changed.
+func (d *Database) CreateIndex(index Index) (IndexResult, error) {
+	body := map[string]interface{}{
+		"index": index.Definition,
+	}
+
+	if index.DesignDoc != "" {
+		body["ddoc"] = index.DesignDoc
+	}
+	if index.Name != "" {
+		body["name"] = index.Name
+	}
+	if index.Type != "" {
+		body["type"] = index.Type
+	}
+	if index.Partitioned != nil {
+		body["partitioned"] = *index.Partitioned
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return IndexResult{}, err
+	}
+
+	var res IndexResult
+	if _, err := d.con.unmarshalRequest("POST", urlConcat(d.Path(), "_index"), NewURLOptions(), bytes.NewBuffer(b), &res); err != nil {
+		return IndexResult{}, err
+	}
+
+	return res, nil
+}
+
+// ListIndexes gets all of the Mango indexes in the database, including the special
+// _all_docs index.
+func (d *Database) ListIndexes() ([]Index, error) {
+	var res struct {
+		Indexes []Index `json:"indexes"`
+	}
+
+	if _, err := d.con.unmarshalRequest("GET", urlConcat(d.Path(), "_index"), NewURLOptions(), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Indexes, nil
+}
+
+// DeleteIndex removes a Mango index from the database. The design document can be given
+// either with or without the "_design/" prefix and indexType is either IndexTypeJSON or
+// IndexTypeText.
+func (d *Database) DeleteIndex(ddoc, indexType, name string) error {
+	path := urlConcat(d.Path(), fmt.Sprintf("_index/%s/%s/%s", strings.TrimPrefix(ddoc, "_design/"), indexType, name))
+
+	var res struct {
+		OK bool `json:"ok"`
+	}
+
+	_, err := d.con.unmarshalRequest("DELETE", path, NewURLOptions(), nil, &res)
+	return err
+}
+
+// ExplainResult describes how CouchDB would run a Mango query.
+type ExplainResult struct {
+	DBName   string                 `json:"dbname"`
+	Index    Index                  `json:"index"`
+	Selector Selector               `json:"selector"`
+	Opts     map[string]interface{} `json:"opts"`
+	Limit    int                    `json:"limit"`
+	Skip     int                    `json:"skip"`
+	Fields   interface{}            `json:"fields"`
+	Range    map[string]interface{} `json:"range,omitempty"`
+	MRArgs   map[string]interface{} `json:"mrargs,omitempty"`
+}
+
+// FullScan returns true if the query would be answered using the special _all_docs index,
+// meaning that every document in the database must be examined.
+func (e ExplainResult) FullScan() bool {
+	return e.Index.Type == IndexTypeSpecial
+}
+
+// UsesIndex returns true if the query would be answered using the index with the provided
+// design document & name. The design document can be given either with or without the
+// "_design/" prefix.
+func (e ExplainResult) UsesIndex(ddoc, name string) bool {
+	return strings.TrimPrefix(e.Index.DesignDoc, "_design/") == strings.TrimPrefix(ddoc, "_design/") && e.Index.Name == name
+}
+
+// Explain returns details of how CouchDB would run a Mango query, including the index which
+// would be used.
+func (d *Database) Explain(query FindQuery) (ExplainResult, error) {
+	return d.explain(d.Path(), query)
+}
+
+// explain explains a Mango query using the _explain endpoint under the provided path.
+func (d *Database) explain(path string, query FindQuery) (ExplainResult, error) {
+	b, err := json.Marshal(query)
+	if err != nil {
+		return ExplainResult{}, err
+	}
+
+	var res ExplainResult
+	if _, err := d.con.unmarshalRequest("POST", urlConcat(path, "_explain"), NewURLOptions(), bytes.NewBuffer(b), &res); err != nil {
+		return ExplainResult{}, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,134 @@
+package sofa
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func TestIndexFieldJSON(t *testing.T) {
+	fields := []IndexField{
+		{Name: "type"},
+		{Name: "name", Type: "desc"},
+		{Name: "description", Type: "string"},
+	}
+
+	b, err := json.Marshal(fields)
+	st.Assert(t, err, nil)
+	st.Assert(t, string(b), `["type",{"name":"desc"},{"name":"description","type":"string"}]`)
+
+	var decoded []IndexField
+	st.Assert(t, json.Unmarshal(b, &decoded), nil)
+	st.Assert(t, decoded, fields)
+
+	st.Reject(t, json.Unmarshal([]byte(`{"a":"asc","b":"desc"}`), &IndexField{}), nil)
+}
+
+func TestCreateIndex(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Post("/mango_test_db/_index").
+		BodyString(`{"ddoc":"fruit-index","index":{"fields":["type",{"name":"asc"}],"partial_filter_selector":{"eaten":{"$exists":false}}},"name":"by-type","partitioned":false,"type":"json"}`).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"result": "created",
+			"id":     "_design/fruit-index",
+			"name":   "by-type",
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("mango_test_db")
+
+	partitioned := false
+	res, err := db.CreateIndex(Index{
+		DesignDoc:   "fruit-index",
+		Name:        "by-type",
+		Type:        IndexTypeJSON,
+		Partitioned: &partitioned,
+		Definition: IndexDefinition{
+			Fields:                []IndexField{{Name: "type"}, {Name: "name", Type: "asc"}},
+			PartialFilterSelector: Exists("eaten", false),
+		},
+	})
+	st.Assert(t, err, nil)
+	st.Assert(t, res, IndexResult{Result: "created", ID: "_design/fruit-index", Name: "by-type"})
+}
+
+func TestListIndexes(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/mango_test_db/_index").
+		Reply(200).
+		BodyString(`{"total_rows":2,"indexes":[
+			{"ddoc":null,"name":"_all_docs","type":"special","def":{"fields":[{"_id":"asc"}]}},
+			{"ddoc":"_design/fruit-index","name":"by-type","type":"json","partitioned":false,"def":{"fields":[{"type":"asc"}]}}
+		]}`)
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("mango_test_db")
+
+	indexes, err := db.ListIndexes()
+	st.Assert(t, err, nil)
+	st.Assert(t, len(indexes), 2)
+
+	st.Assert(t, indexes[0].Type, IndexTypeSpecial)
+	st.Assert(t, indexes[0].DesignDoc, "")
+	st.Assert(t, indexes[1].DesignDoc, "_design/fruit-index")
+	st.Assert(t, *indexes[1].Partitioned, false)
+	st.Assert(t, indexes[1].Definition.Fields, []IndexField{{Name: "type", Type: "asc"}})
+}
+
+func TestDeleteIndex(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Delete("/mango_test_db/_index/fruit-index/json/by-type").
+		Reply(200).
+		JSON(map[string]interface{}{"ok": true})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("mango_test_db")
+
+	st.Assert(t, db.DeleteIndex("_design/fruit-index", IndexTypeJSON, "by-type"), nil)
+	st.Assert(t, gock.IsDone(), true)
+}
+
+func TestExplain(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Post("/mango_test_db/_explain").
+		BodyString(`{"selector":{"type":{"$eq":"fruit"}}}`).
+		Reply(200).
+		BodyString(`{
+			"dbname": "mango_test_db",
+			"index": {"ddoc":"_design/fruit-index","name":"by-type","type":"json","def":{"fields":[{"type":"asc"}]}},
+			"selector": {"type":{"$eq":"fruit"}},
+			"opts": {"use_index":[],"bookmark":"nil","limit":25,"skip":0},
+			"limit": 25,
+			"skip": 0,
+			"fields": "all_fields",
+			"range": {"start_key":["fruit"],"end_key":["fruit","<MAX>"]}
+		}`)
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("mango_test_db")
+
+	res, err := db.Explain(FindQuery{Selector: Eq("type", "fruit")})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, res.DBName, "mango_test_db")
+	st.Assert(t, res.Limit, 25)
+	st.Assert(t, res.Fields, "all_fields")
+	st.Assert(t, res.FullScan(), false)
+	st.Assert(t, res.UsesIndex("fruit-index", "by-type"), true)
+	st.Assert(t, res.UsesIndex("fruit-index", "by-name"), false)
+
+	res.Index.Type = IndexTypeSpecial
+	st.Assert(t, res.FullScan(), true)
+}
@@ -0,0 +1,116 @@
+package sofa
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func TestSelectorBuilder(t *testing.T) {
+	tests := []struct {
+		selector Selector
+		expected string
+	}{
+		{
+			selector: Eq("type", "fruit"),
+			expected: `{"type":{"$eq":"fruit"}}`,
+		},
+		{
+			selector: And(Gt("rating", 3), Lte("rating", 5), Ne("name", "kiwi")),
+			expected: `{"$and":[{"rating":{"$gt":3}},{"rating":{"$lte":5}},{"name":{"$ne":"kiwi"}}]}`,
+		},
+		{
+			selector: Or(In("name", "apple", "pear"), Nin("colour"), Not(Exists("eaten", true))),
+			expected: `{"$or":[{"name":{"$in":["apple","pear"]}},{"colour":{"$nin":[]}},{"$not":{"eaten":{"$exists":true}}}]}`,
+		},
+		{
+			selector: ElemMatch("tags", Eq("", "organic")),
+			expected: `{"tags":{"$elemMatch":{"$eq":"organic"}}}`,
+		},
+		{
+			selector: AllMatch("stock", Gte("count", 1)),
+			expected: `{"stock":{"$allMatch":{"count":{"$gte":1}}}}`,
+		},
+		{
+			selector: Nor(Regex("name", "^a"), Type("name", "number"), Size("tags", 2), Mod("count", 2, 0), All("tags", "a")),
+			expected: `{"$nor":[{"name":{"$regex":"^a"}},{"name":{"$type":"number"}},{"tags":{"$size":2}},{"count":{"$mod":[2,0]}},{"tags":{"$all":["a"]}}]}`,
+		},
+	}
+
+	for n, tc := range tests {
+		b, err := json.Marshal(tc.selector)
+		st.Assert(t, err, nil)
+		st.Expect(t, string(b), tc.expected, n)
+	}
+}
+
+func TestFindQueryJSON(t *testing.T) {
+	b, err := json.Marshal(FindQuery{})
+	st.Assert(t, err, nil)
+	st.Assert(t, string(b), `{"selector":{}}`)
+
+	b, err = json.Marshal(FindQuery{
+		Selector:       Eq("type", "fruit"),
+		Fields:         []string{"_id", "name"},
+		Sort:           []SortField{Asc("type"), Desc("name")},
+		Limit:          10,
+		Skip:           5,
+		UseIndex:       []string{"fruit-index", "by-type"},
+		ExecutionStats: true,
+		Bookmark:       "g1AAAA",
+	})
+	st.Assert(t, err, nil)
+	st.Assert(t, string(b), `{"bookmark":"g1AAAA","execution_stats":true,"fields":["_id","name"],"limit":10,"selector":{"type":{"$eq":"fruit"}},"skip":5,"sort":[{"type":"asc"},{"name":"desc"}],"use_index":["fruit-index","by-type"]}`)
+
+	b, err = json.Marshal(FindQuery{UseIndex: []string{"fruit-index"}})
+	st.Assert(t, err, nil)
+	st.Assert(t, string(b), `{"selector":{},"use_index":"fruit-index"}`)
+}
+
+func TestFind(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Post("/mango_test_db/_find").
+		BodyString(`{"selector":{"type":{"$eq":"fruit"}},"limit":2,"execution_stats":true}`).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"docs": []map[string]interface{}{
+				{"_id": "fruit1", "_rev": DefaultFirstRev, "name": "apple", "type": "fruit"},
+				{"_id": "fruit2", "_rev": DefaultFirstRev, "name": "kiwi", "type": "fruit"},
+			},
+			"bookmark": "g1AAAABweJzLYWBgYMpgSmHgKy5JLCrJTq2MT8lPzkzJBYqzFqWmFRXmpAIAr9QLbQ",
+			"warning":  "No matching index found, create an index to optimize query time.",
+			"execution_stats": map[string]interface{}{
+				"total_keys_examined":        0,
+				"total_docs_examined":        3,
+				"total_quorum_docs_examined": 0,
+				"results_returned":           2,
+				"execution_time_ms":          1.5,
+			},
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("mango_test_db")
+
+	res, err := db.Find(FindQuery{
+		Selector:       Eq("type", "fruit"),
+		Limit:          2,
+		ExecutionStats: true,
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, len(res.Docs), 2)
+	assertPrefix(t, res.Bookmark, "g1AAAA")
+	assertPrefix(t, res.Warning, "No matching index")
+	st.Assert(t, res.ExecutionStats.TotalDocsExamined, int64(3))
+	st.Assert(t, res.ExecutionStats.ResultsReturned, int64(2))
+
+	var docs []taggedTestDoc
+	st.Assert(t, res.UnmarshalDocuments(&docs), nil)
+	st.Assert(t, docs[1].ID, "fruit2")
+	st.Assert(t, docs[1].Rev, DefaultFirstRev)
+}
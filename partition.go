@@ -0,0 +1,178 @@
+package sofa
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PartitionInfo contains information about a single partition of a partitioned database.
+type PartitionInfo struct {
+	DBName    string `json:"db_name"`
+	Partition string `json:"partition"`
+	DocCount  int    `json:"doc_count"`
+	DelCount  int    `json:"doc_del_count"`
+	Sizes     struct {
+		Active   int `json:"active"`
+		External int `json:"external"`
+	} `json:"sizes"`
+}
+
+// Partition represents a single partition of a partitioned database (CouchDB 3+). Queries
+// made through a Partition only examine the documents in that partition, which is much
+// cheaper than querying the whole database.
+type Partition struct {
+	name string
+	db   *Database
+}
+
+// Partition creates a new Partition object for the partition with the provided name. As
+// with Connection.Database no contact with the server is made, although an error is
+// returned if the name is not a valid partition name.
+func (d *Database) Partition(name string) (*Partition, error) {
+	if err := validatePartitionName(name); err != nil {
+		return nil, err
+	}
+
+	return &Partition{
+		name: name,
+		db:   d,
+	}, nil
+}
+
+// Name returns the name of the Partition.
+func (p *Partition) Name() string {
+	return p.name
+}
+
+// Path returns the path for this partition, in the correct form to be passed to
+// Connection.URL().
+func (p *Partition) Path() string {
+	return p.db.partitionPath(p.name)
+}
+
+// DocumentID returns the full ID of the document with the provided ID in this partition.
+func (p *Partition) DocumentID(id string) string {
+	return p.name + ":" + id
+}
+
+// Info gets information about the documents stored in the partition.
+func (p *Partition) Info() (PartitionInfo, error) {
+	var info PartitionInfo
+	if _, err := p.db.con.unmarshalRequest("GET", p.Path(), NewURLOptions(), nil, &info); err != nil {
+		return PartitionInfo{}, err
+	}
+
+	return info, nil
+}
+
+// Get retrieves a single document from the partition in the same way as Database.Get. The
+// ID must be the full document ID, including the partition.
+func (p *Partition) Get(document interface{}, id, rev string) (string, error) {
+	if err := p.validateID(id); err != nil {
+		return "", err
+	}
+
+	return p.db.Get(document, id, rev)
+}
+
+// Put stores a document in the partition in the same way as Database.Put, after checking
+// that the document ID is in this partition.
+func (p *Partition) Put(document interface{}) (string, error) {
+	meta, err := documentMetadata(document)
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.validateID(meta.ID); err != nil {
+		return "", err
+	}
+
+	return p.db.Put(document)
+}
+
+// NamedView creates a new NamedView for a view in the partition. The view must be defined
+// in a partitioned design document.
+func (p *Partition) NamedView(design, name string) NamedView {
+	return NamedView{
+		DesignDoc: design,
+		Name:      name,
+
+		db:        p.db,
+		partition: p.name,
+	}
+}
+
+// AllDocsView creates a new AllDocsView which only includes documents in the partition.
+func (p *Partition) AllDocsView() AllDocsView {
+	return AllDocsView{
+		db:        p.db,
+		partition: p.name,
+	}
+}
+
+// Find runs a Mango query against the documents in the partition.
+func (p *Partition) Find(query FindQuery) (FindResult, error) {
+	return p.db.find(p.Path(), query)
+}
+
+// Explain returns details of how CouchDB would run a Mango query against the partition.
+func (p *Partition) Explain(query FindQuery) (ExplainResult, error) {
+	return p.db.explain(p.Path(), query)
+}
+
+// validateID checks that a document ID is valid for a partitioned database and belongs to
+// this partition.
+func (p *Partition) validateID(id string) error {
+	partition, _, err := SplitPartitionedID(id)
+	if err != nil {
+		return err
+	}
+
+	if partition != p.name {
+		return fmt.Errorf("document %q is not in partition %q", id, p.name)
+	}
+
+	return nil
+}
+
+// SplitPartitionedID splits a document ID from a partitioned database into the partition
+// name and the ID within the partition. An error is returned if the ID is not of the form
+// "partition:id" required by partitioned databases. Design documents and local documents
+// are not in any partition and so are also rejected.
+func SplitPartitionedID(id string) (string, string, error) {
+	partition, docid, found := strings.Cut(id, ":")
+	if !found || docid == "" {
+		return "", "", fmt.Errorf("invalid partitioned document ID %q: must be of the form partition:id", id)
+	}
+
+	if err := validatePartitionName(partition); err != nil {
+		return "", "", fmt.Errorf("invalid partitioned document ID %q: %v", id, err)
+	}
+
+	return partition, docid, nil
+}
+
+// validatePartitionName checks that a partition name is one which CouchDB will accept.
+func validatePartitionName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("partition name cannot be empty")
+	case strings.HasPrefix(name, "_"):
+		return fmt.Errorf("partition name %q cannot begin with an underscore", name)
+	case strings.Contains(name, ":"):
+		return fmt.Errorf("partition name %q cannot contain a colon", name)
+	}
+
+	return nil
+}
+
+// partitionPath returns the path of a partition in this database, or the path of the
+// database itself if the partition name is empty.
+func (d *Database) partitionPath(partition string) string {
+	if partition == "" {
+		return d.Path()
+	}
+
+	return urlConcat(d.Path(), "_partition/"+partition)
+}
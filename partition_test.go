@@ -0,0 +1,159 @@
+package sofa
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func TestCreatePartitionedDatabase(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Put("/partition_test_db").
+		MatchParam("partitioned", "true").
+		Reply(201).
+		JSON(map[string]interface{}{"ok": true})
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Get("/partition_test_db").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"db_name": "partition_test_db",
+			"props":   map[string]interface{}{"partitioned": true},
+		})
+
+	con := globalTestConnections.Version3(t, true)
+
+	db, err := con.CreatePartitionedDatabase("partition_test_db")
+	st.Assert(t, err, nil)
+
+	meta, err := db.Metadata()
+	st.Assert(t, err, nil)
+	st.Assert(t, meta.Props.Partitioned, true)
+}
+
+func TestSplitPartitionedID(t *testing.T) {
+	partition, id, err := SplitPartitionedID("fruit:apple:green")
+	st.Assert(t, err, nil)
+	st.Assert(t, partition, "fruit")
+	st.Assert(t, id, "apple:green")
+
+	for n, id := range []string{"apple", "fruit:", ":apple", "_design:apple", "_design/fruit"} {
+		_, _, err := SplitPartitionedID(id)
+		st.Reject(t, err, nil, n)
+	}
+}
+
+func TestPartitionValidation(t *testing.T) {
+	con := globalTestConnections.Version3(t, true)
+	db := con.Database("partition_test_db")
+
+	_, err := db.Partition("_fruit")
+	st.Reject(t, err, nil)
+
+	p, err := db.Partition("fruit")
+	st.Assert(t, err, nil)
+	st.Assert(t, p.DocumentID("apple"), "fruit:apple")
+
+	// Neither of these make a request as the IDs are rejected first
+	_, err = p.Put(&taggedTestDoc{ID: "vegetable:carrot"})
+	st.Reject(t, err, nil)
+
+	_, err = p.Get(&taggedTestDoc{}, "apple", "")
+	st.Reject(t, err, nil)
+}
+
+func TestPartitionQueries(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Get("/partition_test_db/_partition/fruit").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"db_name":       "partition_test_db",
+			"partition":     "fruit",
+			"doc_count":     2,
+			"doc_del_count": 0,
+			"sizes":         map[string]interface{}{"active": 512, "external": 256},
+		})
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Get("/partition_test_db/_partition/fruit/_all_docs").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 2,
+			"offset":     0,
+			"rows": []map[string]interface{}{
+				{"id": "fruit:apple", "key": "fruit:apple", "value": map[string]interface{}{"rev": DefaultFirstRev}},
+				{"id": "fruit:kiwi", "key": "fruit:kiwi", "value": map[string]interface{}{"rev": DefaultFirstRev}},
+			},
+		})
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Get("/partition_test_db/_partition/fruit/_design/fruit/_view/by-colour").
+		MatchParam("key", `"green"`).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 1,
+			"offset":     0,
+			"rows": []map[string]interface{}{
+				{"id": "fruit:kiwi", "key": "green", "value": nil},
+			},
+		})
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Post("/partition_test_db/_partition/fruit/_find").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"docs": []map[string]interface{}{
+				{"_id": "fruit:kiwi", "_rev": DefaultFirstRev},
+			},
+		})
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Post("/partition_test_db/_partition/fruit/_explain").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"dbname": "partition_test_db",
+			"index":  map[string]interface{}{"ddoc": nil, "name": "_all_docs", "type": "special", "def": map[string]interface{}{"fields": []interface{}{map[string]string{"_id": "asc"}}}},
+		})
+
+	con := globalTestConnections.Version3(t, true)
+	db := con.Database("partition_test_db")
+
+	p, err := db.Partition("fruit")
+	st.Assert(t, err, nil)
+
+	info, err := p.Info()
+	st.Assert(t, err, nil)
+	st.Assert(t, info.Partition, "fruit")
+	st.Assert(t, info.DocCount, 2)
+	st.Assert(t, info.Sizes.Active, 512)
+
+	all := p.AllDocsView()
+	st.Assert(t, all.FullPath(), "partition_test_db/_partition/fruit/_all_docs")
+
+	docs, err := all.Execute(ViewParams{})
+	st.Assert(t, err, nil)
+	st.Assert(t, len(docs.Rows), 2)
+
+	view := p.NamedView("fruit", "by-colour")
+	st.Assert(t, view.FullPath(), "partition_test_db/_partition/fruit/_design/fruit/_view/by-colour")
+
+	docs, err = view.Execute(ViewParams{Key: NewInterfaceParameter("green")})
+	st.Assert(t, err, nil)
+	st.Assert(t, docs.Rows[0].ID, "fruit:kiwi")
+
+	found, err := p.Find(FindQuery{Selector: Eq("colour", "green")})
+	st.Assert(t, err, nil)
+	st.Assert(t, len(found.Docs), 1)
+
+	explain, err := p.Explain(FindQuery{Selector: Eq("colour", "green")})
+	st.Assert(t, err, nil)
+	st.Assert(t, explain.FullScan(), true)
+
+	st.Assert(t, gock.IsDone(), true)
+}
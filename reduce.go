@@ -0,0 +1,207 @@
+package sofa
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ReduceStats is the value returned by the built-in _stats reduce function.
+type ReduceStats struct {
+	Sum    float64 `json:"sum"`
+	Count  float64 `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	SumSqr float64 `json:"sumsqr"`
+}
+
+// Mean returns the mean of the values which were reduced, or 0 if there were none.
+func (s ReduceStats) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return s.Sum / s.Count
+}
+
+// Variance returns the population variance of the values which were reduced, or 0 if there
+// were none.
+func (s ReduceStats) Variance() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+
+	mean := s.Mean()
+	return s.SumSqr/s.Count - mean*mean
+}
+
+// StdDev returns the population standard deviation of the values which were reduced.
+func (s ReduceStats) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// CountValue returns the value of a row from a view using the built-in _count reduce
+// function.
+func (r Row) CountValue() (int64, error) {
+	return reduceInteger("_count", r.Value)
+}
+
+// ApproxCountDistinctValue returns the value of a row from a view using the built-in
+// _approx_count_distinct reduce function.
+func (r Row) ApproxCountDistinctValue() (int64, error) {
+	return reduceInteger("_approx_count_distinct", r.Value)
+}
+
+// SumValue returns the value of a row from a view using the built-in _sum reduce function
+// where the emitted values were numbers.
+func (r Row) SumValue() (float64, error) {
+	f, ok := r.Value.(float64)
+	if !ok {
+		return 0, fmt.Errorf("value is not a _sum result: %v", r.Value)
+	}
+
+	return f, nil
+}
+
+// SumValues returns the value of a row from a view using the built-in _sum reduce function
+// where the emitted values were arrays of numbers, which are summed element by element.
+func (r Row) SumValues() ([]float64, error) {
+	arr, ok := r.Value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value is not an array _sum result: %v", r.Value)
+	}
+
+	sums := make([]float64, len(arr))
+	for i, v := range arr {
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("value is not an array _sum result: %v", r.Value)
+		}
+		sums[i] = f
+	}
+
+	return sums, nil
+}
+
+// StatsValue returns the value of a row from a view using the built-in _stats reduce
+// function.
+func (r Row) StatsValue() (ReduceStats, error) {
+	var stats ReduceStats
+	if _, ok := r.Value.(map[string]interface{}); !ok {
+		return stats, fmt.Errorf("value is not a _stats result: %v", r.Value)
+	}
+
+	b, err := json.Marshal(r.Value)
+	if err != nil {
+		return stats, err
+	}
+
+	err = json.Unmarshal(b, &stats)
+	return stats, err
+}
+
+func reduceInteger(reducer string, v interface{}) (int64, error) {
+	f, ok := v.(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, fmt.Errorf("value is not a %s result: %v", reducer, v)
+	}
+
+	return int64(f), nil
+}
+
+// ReduceGroup is a node in a tree of reduced view rows built by DocumentList.GroupTree. The
+// embedded Row holds the key prefix for the group & the reduced value for that prefix, if
+// the results included one. The Groups are the groups one level further down, keyed by the
+// next part of their key (see GroupName).
+type ReduceGroup struct {
+	Row
+	Groups map[string]*ReduceGroup
+}
+
+// GroupName returns the name used as the key in ReduceGroup.Groups for a part of a view
+// key, which is the part encoded as JSON. Every part is encoded so that values of different
+// types can never share a name: the number 2024 has the name 2024 while the string "2024"
+// has the name "2024" (including the quotes). Group can be used to look up groups without
+// building the names.
+func GroupName(part interface{}) string {
+	b, err := json.Marshal(jsonValue(part))
+	if err != nil {
+		return fmt.Sprint(part)
+	}
+
+	return string(b)
+}
+
+// Group returns the descendant group with the provided key parts below this group, or nil
+// if there is no such group. It is safe to call Group on a nil ReduceGroup, so calls can be
+// chained.
+func (g *ReduceGroup) Group(parts ...interface{}) *ReduceGroup {
+	for _, part := range parts {
+		if g == nil {
+			return nil
+		}
+		g = g.Groups[GroupName(part)]
+	}
+
+	return g
+}
+
+// SortedGroups returns the groups one level below this group, sorted by their keys in view
+// collation order.
+func (g *ReduceGroup) SortedGroups() []*ReduceGroup {
+	groups := make([]*ReduceGroup, 0, len(g.Groups))
+	for _, group := range g.Groups {
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return Collate(groups[i].Key, groups[j].Key) < 0
+	})
+
+	return groups
+}
+
+// GroupTree builds a tree from the rows of a reduced view queried using group or
+// group_level, where each level of the tree is one part of the array keys. For example the
+// rows of a view with [year, month] keys queried with group_level=2 can be accessed using
+// tree.Group(2024, 3). Rows from queries using different group levels can be combined
+// into a single DocumentList first to populate the values of intermediate groups as well.
+// A row with a null key (from a query without grouping) provides the value of the root
+// group and keys which are not arrays are treated as a single part key.
+func (dl *DocumentList) GroupTree() *ReduceGroup {
+	root := &ReduceGroup{Row: Row{Key: []interface{}{}}}
+
+	for _, row := range dl.Rows {
+		var parts []interface{}
+		switch key := row.Key.(type) {
+		case nil:
+			root.Value = row.Value
+			continue
+		case []interface{}:
+			parts = key
+		default:
+			parts = []interface{}{key}
+		}
+
+		group := root
+		for i, part := range parts {
+			name := GroupName(part)
+			child, ok := group.Groups[name]
+			if !ok {
+				if group.Groups == nil {
+					group.Groups = map[string]*ReduceGroup{}
+				}
+
+				prefix := append([]interface{}{}, parts[:i+1]...)
+				child = &ReduceGroup{Row: Row{Key: prefix}}
+				group.Groups[name] = child
+			}
+			group = child
+		}
+
+		group.Value = row.Value
+	}
+
+	return root
+}
@@ -0,0 +1,111 @@
+package sofa
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func TestReduceValues(t *testing.T) {
+	count, err := Row{Value: float64(12)}.CountValue()
+	st.Assert(t, err, nil)
+	st.Assert(t, count, int64(12))
+
+	_, err = Row{Value: 1.5}.CountValue()
+	st.Reject(t, err, nil)
+
+	distinct, err := Row{Value: float64(3)}.ApproxCountDistinctValue()
+	st.Assert(t, err, nil)
+	st.Assert(t, distinct, int64(3))
+
+	sum, err := Row{Value: 7.5}.SumValue()
+	st.Assert(t, err, nil)
+	st.Assert(t, sum, 7.5)
+
+	_, err = Row{Value: "7.5"}.SumValue()
+	st.Reject(t, err, nil)
+
+	sums, err := Row{Value: []interface{}{float64(1), float64(2)}}.SumValues()
+	st.Assert(t, err, nil)
+	st.Assert(t, sums, []float64{1, 2})
+
+	stats, err := Row{Value: map[string]interface{}{
+		"sum":    float64(10),
+		"count":  float64(4),
+		"min":    float64(1),
+		"max":    float64(4),
+		"sumsqr": float64(30),
+	}}.StatsValue()
+	st.Assert(t, err, nil)
+	st.Assert(t, stats, ReduceStats{Sum: 10, Count: 4, Min: 1, Max: 4, SumSqr: 30})
+	st.Assert(t, stats.Mean(), 2.5)
+	st.Assert(t, stats.Variance(), 1.25)
+
+	_, err = Row{Value: float64(10)}.StatsValue()
+	st.Reject(t, err, nil)
+}
+
+func TestGroupTree(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/view_test_db/_design/sales/_view/by-month").
+		MatchParam("group_level", "2").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"rows": []map[string]interface{}{
+				{"key": []interface{}{2023, 12}, "value": 4},
+				{"key": []interface{}{2024, 1}, "value": 2},
+				{"key": []interface{}{2024, 3}, "value": 5},
+			},
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("view_test_db")
+
+	result, err := db.NamedView("sales", "by-month").Execute(ViewParams{GroupLevel: 2})
+	st.Assert(t, err, nil)
+
+	tree := result.GroupTree()
+	st.Assert(t, len(tree.Groups), 2)
+	st.Assert(t, tree.Group(2024).Key, []interface{}{float64(2024)})
+	st.Assert(t, tree.Group(2024).Value, nil)
+	st.Assert(t, tree.Groups[GroupName(2024)].Groups["3"].Key, []interface{}{float64(2024), float64(3)})
+
+	count, err := tree.Group(2024, 3).CountValue()
+	st.Assert(t, err, nil)
+	st.Assert(t, count, int64(5))
+
+	st.Assert(t, tree.Group(2024, 2), (*ReduceGroup)(nil))
+	st.Assert(t, tree.Group(2022, 1), (*ReduceGroup)(nil))
+
+	months := tree.Group(2024).SortedGroups()
+	st.Assert(t, len(months), 2)
+	st.Assert(t, months[0].Key, []interface{}{float64(2024), float64(1)})
+
+	// Combining with an ungrouped query provides the value of the root
+	result.Rows = append(result.Rows, Row{Key: nil, Value: float64(11)})
+	count, err = result.GroupTree().CountValue()
+	st.Assert(t, err, nil)
+	st.Assert(t, count, int64(11))
+}
+
+func TestGroupTreeKeyTypes(t *testing.T) {
+	result := DocumentList{Rows: []Row{
+		{Key: []interface{}{float64(2024)}, Value: float64(1)},
+		{Key: []interface{}{"2024"}, Value: float64(2)},
+		{Key: []interface{}{nil}, Value: float64(3)},
+		{Key: []interface{}{"null"}, Value: float64(4)},
+	}}
+
+	// Values of different types are kept in separate groups
+	tree := result.GroupTree()
+	st.Assert(t, len(tree.Groups), 4)
+	st.Assert(t, tree.Group(2024).Value, float64(1))
+	st.Assert(t, tree.Group("2024").Value, float64(2))
+	st.Assert(t, tree.Group(nil).Value, float64(3))
+	st.Assert(t, tree.Group("null").Value, float64(4))
+	st.Assert(t, GroupName("2024"), `"2024"`)
+}
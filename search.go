@@ -0,0 +1,286 @@
+package sofa
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// SearchRange is a single named range used to count the results of a search which have a
+// numeric field within the range. Both ends of the range are inclusive unless MinExclusive
+// or MaxExclusive are set. Use math.Inf for a range which is unbounded at either end.
+type SearchRange struct {
+	Label        string
+	Min          float64
+	Max          float64
+	MinExclusive bool
+	MaxExclusive bool
+}
+
+// SearchParams are the parameters for a full-text search query made using Database.Search:
+//   - Query is the Lucene query to run.
+//   - Sort orders the results using field names, optionally with a "-" prefix for
+//     descending order and a type suffix such as "<number>".
+//   - Limit is the maximum number of results to return.
+//   - Bookmark is the bookmark returned from a previous search, used to get the next
+//     page of results.
+//   - IncludeDocs includes the full content of each document in the results.
+//   - Counts are fields for which to count the number of results having each value.
+//   - Ranges are fields for which to count the number of results within each range.
+//   - Drilldown restricts the results to documents with the field & value pairs.
+//   - Highlight fields have the matching terms highlighted in the results, using the
+//     other Highlight parameters if set.
+//   - Nouveau queries a Nouveau index (using _nouveau) instead of a Clouseau index (using
+//     _search). Drilldown & Highlight are not supported by Nouveau indexes.
+type SearchParams struct {
+	Query       string
+	Sort        []string
+	Limit       int
+	Bookmark    string
+	IncludeDocs bool
+	Counts      []string
+	Ranges      map[string][]SearchRange
+	Drilldown   [][2]string
+
+	Highlight        []string
+	HighlightPreTag  string
+	HighlightPostTag string
+	HighlightNumber  int
+	HighlightSize    int
+
+	Nouveau bool
+}
+
+// MarshalJSON implements json.Marshaler to produce the request body for _search or
+// _nouveau, depending on the type of index being searched.
+func (p SearchParams) MarshalJSON() ([]byte, error) {
+	body := map[string]interface{}{
+		"query": p.Query,
+	}
+
+	if len(p.Sort) > 0 {
+		body["sort"] = p.Sort
+	}
+	if p.Limit > 0 {
+		body["limit"] = p.Limit
+	}
+	if p.Bookmark != "" {
+		body["bookmark"] = p.Bookmark
+	}
+	if p.IncludeDocs {
+		body["include_docs"] = true
+	}
+	if len(p.Counts) > 0 {
+		body["counts"] = p.Counts
+	}
+	if len(p.Ranges) > 0 {
+		if p.Nouveau {
+			body["ranges"] = nouveauRanges(p.Ranges)
+		} else {
+			body["ranges"] = luceneRanges(p.Ranges)
+		}
+	}
+	if len(p.Drilldown) > 0 {
+		body["drilldown"] = p.Drilldown
+	}
+	if len(p.Highlight) > 0 {
+		body["highlight_fields"] = p.Highlight
+	}
+	if p.HighlightPreTag != "" {
+		body["highlight_pre_tag"] = p.HighlightPreTag
+	}
+	if p.HighlightPostTag != "" {
+		body["highlight_post_tag"] = p.HighlightPostTag
+	}
+	if p.HighlightNumber > 0 {
+		body["highlight_number"] = p.HighlightNumber
+	}
+	if p.HighlightSize > 0 {
+		body["highlight_size"] = p.HighlightSize
+	}
+
+	return json.Marshal(body)
+}
+
+// luceneRanges converts ranges to the Lucene range syntax used by Clouseau indexes, for
+// example {"price": {"cheap": "[0 TO 10}"}}.
+func luceneRanges(ranges map[string][]SearchRange) map[string]map[string]string {
+	out := map[string]map[string]string{}
+	for field, rs := range ranges {
+		out[field] = map[string]string{}
+		for _, r := range rs {
+			open, closing := "[", "]"
+			if r.MinExclusive {
+				open = "{"
+			}
+			if r.MaxExclusive {
+				closing = "}"
+			}
+
+			out[field][r.Label] = fmt.Sprintf("%s%s TO %s%s", open, luceneNumber(r.Min), luceneNumber(r.Max), closing)
+		}
+	}
+
+	return out
+}
+
+func luceneNumber(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// nouveauRanges converts ranges to the form used by Nouveau indexes, in which an unbounded
+// end of the range is omitted.
+func nouveauRanges(ranges map[string][]SearchRange) map[string][]map[string]interface{} {
+	out := map[string][]map[string]interface{}{}
+	for field, rs := range ranges {
+		out[field] = []map[string]interface{}{}
+		for _, r := range rs {
+			nr := map[string]interface{}{
+				"label":         r.Label,
+				"min_inclusive": !r.MinExclusive,
+				"max_inclusive": !r.MaxExclusive,
+			}
+			if !math.IsInf(r.Min, 0) {
+				nr["min"] = r.Min
+			}
+			if !math.IsInf(r.Max, 0) {
+				nr["max"] = r.Max
+			}
+
+			out[field] = append(out[field], nr)
+		}
+	}
+
+	return out
+}
+
+// SearchRow is a single result from a full-text search.
+type SearchRow struct {
+	ID         string                 `json:"id"`
+	Order      []interface{}          `json:"order"`
+	Fields     map[string]interface{} `json:"fields"`
+	Highlights map[string][]string    `json:"highlights,omitempty"`
+	Doc        json.RawMessage        `json:"doc,omitempty"`
+}
+
+// SearchResult is the result of a full-text search. Counts & Ranges contain the number of
+// results for each value or range of the fields requested in the SearchParams.
+type SearchResult struct {
+	TotalRows int
+	Bookmark  string
+	Rows      []SearchRow
+	Counts    map[string]map[string]int
+	Ranges    map[string]map[string]int
+}
+
+// searchResponse contains the fields of the response from both _search & _nouveau, which
+// use different names for the rows and the total.
+type searchResponse struct {
+	TotalRows int                       `json:"total_rows"`
+	TotalHits int                       `json:"total_hits"`
+	Bookmark  string                    `json:"bookmark"`
+	Rows      []SearchRow               `json:"rows"`
+	Hits      []SearchRow               `json:"hits"`
+	Counts    map[string]map[string]int `json:"counts"`
+	Ranges    map[string]map[string]int `json:"ranges"`
+}
+
+// Search runs a full-text search query against an index defined in a design document
+// (CouchDB 3+ with search enabled). The design document can be given either with or
+// without the "_design/" prefix. To page through the results set the Bookmark of the
+// params to the Bookmark from the previous SearchResult.
+func (d *Database) Search(ddoc, index string, params SearchParams) (SearchResult, error) {
+	endpoint := "_search"
+	if params.Nouveau {
+		endpoint = "_nouveau"
+	}
+
+	b, err := json.Marshal(params)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	var res searchResponse
+	path := urlConcat(d.DesignPath(ddoc), endpoint+"/"+index)
+	if _, err := d.con.unmarshalRequest("POST", path, NewURLOptions(), bytes.NewBuffer(b), &res); err != nil {
+		return SearchResult{}, err
+	}
+
+	result := SearchResult{
+		TotalRows: res.TotalRows,
+		Bookmark:  res.Bookmark,
+		Rows:      res.Rows,
+		Counts:    res.Counts,
+		Ranges:    res.Ranges,
+	}
+
+	if params.Nouveau {
+		result.TotalRows = res.TotalHits
+		result.Rows = res.Hits
+	}
+
+	return result, nil
+}
+
+// UnmarshalDocuments unmarshals the documents included in the results (when IncludeDocs
+// was set) into the provided value, which should be a pointer to a slice.
+func (r SearchResult) UnmarshalDocuments(docs interface{}) error {
+	raw := make([]json.RawMessage, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		raw = append(raw, row.Doc)
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, docs)
+}
+
+// SearchIndexInfo contains information about the state of a full-text search index. The
+// fields which are set depend on the type of the index: Clouseau indexes report
+// PendingSeq, CommittedSeq, DocCount & DocDelCount while Nouveau indexes report UpdateSeq,
+// PurgeSeq & NumDocs.
+type SearchIndexInfo struct {
+	Name  string `json:"name"`
+	Index struct {
+		PendingSeq   int64 `json:"pending_seq"`
+		CommittedSeq int64 `json:"committed_seq"`
+		DocCount     int64 `json:"doc_count"`
+		DocDelCount  int64 `json:"doc_del_count"`
+		UpdateSeq    int64 `json:"update_seq"`
+		PurgeSeq     int64 `json:"purge_seq"`
+		NumDocs      int64 `json:"num_docs"`
+		DiskSize     int64 `json:"disk_size"`
+	} `json:"search_index"`
+}
+
+// SearchInfo gets information about a Clouseau full-text search index using _search_info.
+func (d *Database) SearchInfo(ddoc, index string) (SearchIndexInfo, error) {
+	return d.searchInfo(ddoc, "_search_info", index)
+}
+
+// NouveauInfo gets information about a Nouveau full-text search index using _nouveau_info.
+func (d *Database) NouveauInfo(ddoc, index string) (SearchIndexInfo, error) {
+	return d.searchInfo(ddoc, "_nouveau_info", index)
+}
+
+func (d *Database) searchInfo(ddoc, endpoint, index string) (SearchIndexInfo, error) {
+	var info SearchIndexInfo
+	path := urlConcat(d.DesignPath(ddoc), endpoint+"/"+index)
+	if _, err := d.con.unmarshalRequest("GET", path, NewURLOptions(), nil, &info); err != nil {
+		return SearchIndexInfo{}, err
+	}
+
+	return info, nil
+}
@@ -0,0 +1,151 @@
+package sofa
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func TestSearchParamsJSON(t *testing.T) {
+	params := SearchParams{
+		Query:  "type:fruit",
+		Sort:   []string{"-price"},
+		Limit:  10,
+		Counts: []string{"colour"},
+		Ranges: map[string][]SearchRange{
+			"price": {
+				{Label: "cheap", Min: 0, Max: 1.5, MaxExclusive: true},
+				{Label: "expensive", Min: 1.5, Max: math.Inf(1)},
+			},
+		},
+		Drilldown: [][2]string{{"colour", "green"}},
+		Highlight: []string{"description"},
+	}
+
+	b, err := json.Marshal(params)
+	st.Assert(t, err, nil)
+	st.Assert(t, string(b), `{"counts":["colour"],"drilldown":[["colour","green"]],"highlight_fields":["description"],"limit":10,"query":"type:fruit","ranges":{"price":{"cheap":"[0 TO 1.5}","expensive":"[1.5 TO Infinity]"}},"sort":["-price"]}`)
+
+	params = SearchParams{
+		Query:   "type:fruit",
+		Ranges:  map[string][]SearchRange{"price": {{Label: "expensive", Min: 1.5, Max: math.Inf(1), MinExclusive: true}}},
+		Nouveau: true,
+	}
+
+	b, err = json.Marshal(params)
+	st.Assert(t, err, nil)
+	st.Assert(t, string(b), `{"query":"type:fruit","ranges":{"price":[{"label":"expensive","max_inclusive":true,"min":1.5,"min_inclusive":false}]}}`)
+}
+
+func TestSearch(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Post("/search_test_db/_design/fruit/_search/by-description").
+		BodyString(`{"query":"description:crunchy","include_docs":true,"counts":["colour"]}`).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 1,
+			"bookmark":   "g1AAAAB",
+			"rows": []map[string]interface{}{
+				{
+					"id":     "apple",
+					"order":  []interface{}{1.2, 0},
+					"fields": map[string]interface{}{"colour": "green"},
+					"doc":    map[string]interface{}{"_id": "apple", "_rev": DefaultFirstRev},
+				},
+			},
+			"counts": map[string]interface{}{"colour": map[string]interface{}{"green": 1}},
+		})
+
+	con := globalTestConnections.Version3(t, true)
+	db := con.Database("search_test_db")
+
+	res, err := db.Search("_design/fruit", "by-description", SearchParams{
+		Query:       "description:crunchy",
+		IncludeDocs: true,
+		Counts:      []string{"colour"},
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, res.TotalRows, 1)
+	st.Assert(t, res.Bookmark, "g1AAAAB")
+	st.Assert(t, res.Rows[0].ID, "apple")
+	st.Assert(t, res.Rows[0].Fields["colour"], "green")
+	st.Assert(t, res.Counts["colour"]["green"], 1)
+
+	var docs []taggedTestDoc
+	st.Assert(t, res.UnmarshalDocuments(&docs), nil)
+	st.Assert(t, docs[0].Rev, DefaultFirstRev)
+}
+
+func TestSearchNouveau(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Post("/search_test_db/_design/fruit/_nouveau/by-description").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_hits":          2,
+			"total_hits_relation": "EQUAL_TO",
+			"bookmark":            "W10=",
+			"hits": []map[string]interface{}{
+				{"id": "apple", "order": []interface{}{}, "fields": map[string]interface{}{}},
+				{"id": "kiwi", "order": []interface{}{}, "fields": map[string]interface{}{}},
+			},
+		})
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Get("/search_test_db/_design/fruit/_nouveau_info/by-description").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"name":         "_design/fruit/by-description",
+			"search_index": map[string]interface{}{"update_seq": 12, "purge_seq": 0, "num_docs": 2, "disk_size": 4096},
+		})
+
+	con := globalTestConnections.Version3(t, true)
+	db := con.Database("search_test_db")
+
+	res, err := db.Search("fruit", "by-description", SearchParams{Query: "description:crunchy", Nouveau: true})
+	st.Assert(t, err, nil)
+	st.Assert(t, res.TotalRows, 2)
+	st.Assert(t, len(res.Rows), 2)
+	st.Assert(t, res.Rows[1].ID, "kiwi")
+
+	info, err := db.NouveauInfo("fruit", "by-description")
+	st.Assert(t, err, nil)
+	st.Assert(t, info.Name, "_design/fruit/by-description")
+	st.Assert(t, info.Index.UpdateSeq, int64(12))
+	st.Assert(t, info.Index.NumDocs, int64(2))
+}
+
+func TestSearchInfo(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Get("/search_test_db/_design/fruit/_search_info/by-description").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"name": "_design/fruit/by-description",
+			"search_index": map[string]interface{}{
+				"pending_seq":   12,
+				"doc_del_count": 1,
+				"doc_count":     2,
+				"disk_size":     4096,
+				"committed_seq": 10,
+			},
+		})
+
+	con := globalTestConnections.Version3(t, true)
+	db := con.Database("search_test_db")
+
+	info, err := db.SearchInfo("fruit", "by-description")
+	st.Assert(t, err, nil)
+	st.Assert(t, info.Index.PendingSeq, int64(12))
+	st.Assert(t, info.Index.CommittedSeq, int64(10))
+	st.Assert(t, info.Index.DocCount, int64(2))
+}
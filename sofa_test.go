@@ -90,6 +90,8 @@ func (tc *TestConnections) testInnerConnection(t *testing.T, mock bool, version
 		t.Fatalf("%v\n", err)
 	}
 
+	con.version = version
+
 	if mock {
 		gock.InterceptClient(con.http)
 	}
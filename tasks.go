@@ -3,12 +3,25 @@ package sofa
 // Task structs contain information about a single task on the server. Examples
 // of tasks represented include database compaction & indexing.
 type Task struct {
-	ChangesDone  int64  `json:"changes_done"`
-	Database     string `json:"database"`
-	PID          string `json:"pid"`
-	Progress     int64  `json:"progress"`
-	StartedOn    int64  `json:"started_on"`
-	TotalChanges int64  `json:"total_changes"`
-	Type         string `json:"type"`
-	UpdatedOn    int64  `json:"updated_on"`
+	ChangesDone    int64  `json:"changes_done"`
+	Database       string `json:"database"`
+	DesignDocument string `json:"design_document,omitempty"`
+	Node           string `json:"node,omitempty"`
+	PID            string `json:"pid"`
+	Progress       int64  `json:"progress"`
+	StartedOn      int64  `json:"started_on"`
+	TotalChanges   int64  `json:"total_changes"`
+	Type           string `json:"type"`
+	UpdatedOn      int64  `json:"updated_on"`
+}
+
+// ActiveTasks gets the list of tasks which are currently running on the server. Admin
+// access is required.
+func (con *Connection) ActiveTasks() ([]Task, error) {
+	var tasks []Task
+	if _, err := con.unmarshalRequest("GET", "/_active_tasks", NewURLOptions(), nil, &tasks); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
 }
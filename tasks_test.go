@@ -1 +1,41 @@
 package sofa
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func TestActiveTasks(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/_active_tasks").
+		Reply(200).
+		JSON([]map[string]interface{}{
+			{
+				"changes_done":    1000,
+				"database":        "shards/00000000-7fffffff/tasks_test_db.1554252384",
+				"design_document": "_design/fruit",
+				"node":            "couchdb@127.0.0.1",
+				"pid":             "<0.1234.0>",
+				"progress":        50,
+				"started_on":      1554252400,
+				"total_changes":   2000,
+				"type":            "indexer",
+				"updated_on":      1554252410,
+			},
+		})
+
+	con := globalTestConnections.Version2(t, true)
+
+	tasks, err := con.ActiveTasks()
+	st.Assert(t, err, nil)
+	st.Assert(t, len(tasks), 1)
+	st.Assert(t, tasks[0].Type, "indexer")
+	st.Assert(t, tasks[0].DesignDocument, "_design/fruit")
+	st.Assert(t, tasks[0].Progress, int64(50))
+	st.Assert(t, taskDatabaseName(tasks[0].Database), "tasks_test_db")
+}
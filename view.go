@@ -3,8 +3,12 @@ package sofa
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"reflect"
+	"strings"
 
 	"github.com/google/go-querystring/query"
 )
@@ -128,8 +132,13 @@ func (i InterfaceListParameter) MarshalJSON() ([]byte, error) {
 //    Setting this to false offers a performance boost. The total_rows and
 //    offset fields are not available when this is set to false.
 //    Default is true
+//  - partition (string) – Query the view within a single partition of a
+//    partitioned database (CouchDB 3+). Optional
+//  - stable (boolean) – Whether or not the view results should be returned
+//    from a stable set of shards (CouchDB 2.1+). Default is false
 //  - stale (string) – Allow the results from a stale view to be used.
-//    Supported values: ok and update_after. Optional
+//    Supported values: ok and update_after. Deprecated in CouchDB 3, where
+//    it is translated to the equivalent stable & update values. Optional
 //  - startkey (json) – Return records starting with the specified key.
 //    Optional
 //  - start_key (json) – Alias for startkey param
@@ -137,6 +146,9 @@ func (i InterfaceListParameter) MarshalJSON() ([]byte, error) {
 //    document ID. Requires startkey to be specified for this to have any
 //    effect. Optional
 //  - start_key_doc_id (string) – Alias for startkey_docid param
+//  - update (string) – Whether or not the view should be updated before
+//    the results are returned (CouchDB 2.1+). Supported values: true, false
+//    and lazy. Default is true
 //  - update_seq (boolean) – Response includes an update_seq value
 //    indicating which sequence id of the database the view reflects.
 //    Default is false
@@ -144,6 +156,7 @@ type ViewParams struct {
 	Conflicts              BooleanParameter        `url:"conflicts,omitempty"`
 	Descending             BooleanParameter        `url:"descending,omitempty"`
 	EndKey                 *InterfaceParameter     `url:"endkey,omitempty"`
+	EndKeyAlias            *InterfaceParameter     `url:"end_key,omitempty"`
 	EndKeyDocID            string                  `url:"endkey_docid,omitempty"`
 	Group                  BooleanParameter        `url:"group,omitempty"`
 	GroupLevel             float64                 `url:"group_level,omitempty"`
@@ -156,10 +169,14 @@ type ViewParams struct {
 	Limit                  float64                 `url:"limit,omitempty"`
 	Reduce                 BooleanParameter        `url:"reduce,omitempty"`
 	Skip                   float64                 `url:"skip,omitempty"`
+	Partition              string                  `url:"-"`
 	Sorted                 BooleanParameter        `url:"sorted,omitempty"`
+	Stable                 BooleanParameter        `url:"stable,omitempty"`
 	Stale                  string                  `url:"stale,omitempty"`
 	StartKey               *InterfaceParameter     `url:"startkey,omitempty"`
+	StartKeyAlias          *InterfaceParameter     `url:"start_key,omitempty"`
 	StartKeyDocID          string                  `url:"startkey_docid,omitempty"`
+	Update                 string                  `url:"update,omitempty"`
 	UpdateSeq              BooleanParameter        `url:"update_seq,omitempty"`
 }
 
@@ -169,6 +186,36 @@ func (v ViewParams) Values() (url.Values, error) {
 	return query.Values(v)
 }
 
+// bodyValues converts a ViewParams to a map which can be sent as the JSON body of a
+// POST request to a view, using the same names as the query string parameters.
+func (v ViewParams) bodyValues() map[string]interface{} {
+	body := map[string]interface{}{}
+
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rv.Field(i)
+		if field.IsZero() {
+			continue
+		}
+
+		name := strings.Split(rt.Field(i).Tag.Get("url"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if b, ok := field.Interface().(BooleanParameter); ok {
+			body[name] = ToBoolean(b)
+		} else if name == "update" && v.Update != "lazy" {
+			body[name] = v.Update == "true"
+		} else {
+			body[name] = field.Interface()
+		}
+	}
+
+	return body
+}
+
 // View is an interface representing the way that views are executed and
 // their results returned.
 type View interface {
@@ -196,14 +243,29 @@ func (d *Database) TemporaryView(mapFunc string) TemporaryView {
 	}
 }
 
-// Execute implements View for TemporaryView.
+// Execute implements View for TemporaryView. CouchDB 2.0 removed _temp_view so on
+// newer servers the view is instead saved in a uniquely named design document, which is
+// queried and then deleted (along with its index files) once the results are returned. If
+// the design document cannot be deleted then the results are returned together with a
+// CleanupError. Other connections try _temp_view first and fall back to a design document
+// if the server responds that it is gone, so a CouchDB 1 connection to a newer server
+// still works.
 func (v TemporaryView) Execute(params ViewParams) (DocumentList, error) {
+	if v.db.con.version >= 2 {
+		return v.executeDesign(params)
+	}
+
+	tempParams, err := params.forVersion(v.db.con.version)
+	if err != nil {
+		return DocumentList{}, err
+	}
+
 	jsString, err := json.Marshal(v)
 	if err != nil {
 		return DocumentList{}, err
 	}
 
-	opts, err := params.Values()
+	opts, err := tempParams.Values()
 	if err != nil {
 		return DocumentList{}, err
 	}
@@ -211,12 +273,56 @@ func (v TemporaryView) Execute(params ViewParams) (DocumentList, error) {
 	var docs DocumentList
 	_, err = v.db.con.unmarshalRequest("POST", v.db.ViewPath("_temp_view"), opts, bytes.NewBuffer(jsString), &docs)
 	if err != nil {
+		if ErrorStatus(err, http.StatusGone) {
+			return v.executeDesign(params)
+		}
+
 		return DocumentList{}, err
 	}
 
 	return docs, nil
 }
 
+// temporaryViewName is the name of the view in the design documents used to emulate
+// temporary views.
+const temporaryViewName = "temp"
+
+// executeDesign emulates a temporary view by saving it in a throwaway design document. The
+// design document is explicitly not partitioned as design documents in partitioned
+// databases are partitioned by default on CouchDB 3, which would prevent the view from
+// being queried globally. CouchDB 2 ignores the option.
+func (v TemporaryView) executeDesign(params ViewParams) (docs DocumentList, err error) {
+	suffix, err := randomHex(16)
+	if err != nil {
+		return DocumentList{}, err
+	}
+
+	name := "sofa_temp_" + suffix
+	rev, err := v.db.putDesign("_design/"+name, map[string]interface{}{
+		"language": "javascript",
+		"options":  map[string]interface{}{"partitioned": false},
+		"views":    map[string]interface{}{temporaryViewName: v},
+	})
+	if err != nil {
+		return DocumentList{}, err
+	}
+
+	defer func() {
+		id := "_design/" + name
+		if cerr := v.db.deleteDocument(id, rev); cerr != nil {
+			if err == nil {
+				err = CleanupError{DesignDoc: id, Err: cerr}
+			}
+			return
+		}
+
+		// Removing the index files is best effort as it requires admin access.
+		v.db.ViewCleanup()
+	}()
+
+	return v.db.NamedView(name, temporaryViewName).Execute(params)
+}
+
 // NamedView represents a view stored on a design document in the database.
 // It must be accessed with both the name of the design document and the
 // name of the view.
@@ -224,7 +330,8 @@ type NamedView struct {
 	DesignDoc string
 	Name      string
 
-	db *Database
+	db        *Database
+	partition string
 }
 
 // NamedView creates a new NamedView for this database. This can then be used
@@ -240,25 +347,196 @@ func (d *Database) NamedView(design, name string) NamedView {
 
 // Execute implements View for NamedView.
 func (v NamedView) Execute(params ViewParams) (DocumentList, error) {
-	opts, err := params.Values()
+	return v.db.queryView(v.queryPath(), params)
+}
+
+// ExecuteMany runs several queries against the NamedView, returning one DocumentList for
+// each of the provided ViewParams in the same order. CouchDB 2.2+ runs all of the queries
+// in a single request but on older servers the queries are run one after another.
+func (v NamedView) ExecuteMany(params []ViewParams) ([]DocumentList, error) {
+	return v.db.queryViewMany(v.queryPath(), params)
+}
+
+// Path gets the path of the NamedView relative to the database root.
+func (v NamedView) Path() string {
+	return fmt.Sprintf("_design/%s/_view/%s", v.DesignDoc, v.Name)
+}
+
+// FullPath gets the path of the NamedView relative to the server root.
+func (v NamedView) FullPath() string {
+	return strings.TrimPrefix(v.queryPath(), "/")
+}
+
+// queryPath gets the path used to query the NamedView, which is within the partition
+// for views created from a Partition.
+func (v NamedView) queryPath() string {
+	return urlConcat(v.db.partitionPath(v.partition), v.Path())
+}
+
+// AllDocsView represents the built-in _all_docs view of the database, which has
+// a row for every document keyed by the document ID.
+type AllDocsView struct {
+	db        *Database
+	partition string
+}
+
+// AllDocsView creates a new AllDocsView for this database. Unlike AllDocuments &
+// ListDocuments this allows the full set of ViewParams to be used.
+func (d *Database) AllDocsView() AllDocsView {
+	return AllDocsView{
+		db: d,
+	}
+}
+
+// Execute implements View for AllDocsView.
+func (v AllDocsView) Execute(params ViewParams) (DocumentList, error) {
+	return v.db.queryView(v.queryPath(), params)
+}
+
+// ExecuteMany runs several queries against _all_docs in the same way as
+// NamedView.ExecuteMany.
+func (v AllDocsView) ExecuteMany(params []ViewParams) ([]DocumentList, error) {
+	return v.db.queryViewMany(v.queryPath(), params)
+}
+
+// Path gets the path of the AllDocsView relative to the database root.
+func (v AllDocsView) Path() string {
+	return "_all_docs"
+}
+
+// FullPath gets the path of the AllDocsView relative to the server root.
+func (v AllDocsView) FullPath() string {
+	return strings.TrimPrefix(v.queryPath(), "/")
+}
+
+// queryPath gets the path used to query the AllDocsView, which is within the partition
+// for views created from a Partition.
+func (v AllDocsView) queryPath() string {
+	return urlConcat(v.db.partitionPath(v.partition), v.Path())
+}
+
+// maxViewURLLength is the longest URL which will be used to query a view with a GET
+// request. Longer queries are sent using POST on servers which support it to avoid the
+// limits on URL length which are imposed by CouchDB & any proxies in front of it.
+const maxViewURLLength = 4096
+
+// queryView queries the view at the provided path and decodes all of the results.
+func (d *Database) queryView(path string, params ViewParams) (DocumentList, error) {
+	resp, err := d.viewRequest(path, params, true)
 	if err != nil {
 		return DocumentList{}, err
 	}
 
 	var docs DocumentList
-	if _, err := v.db.con.unmarshalRequest("GET", v.db.ViewPath(v.Path()), opts, nil, &docs); err != nil {
+	if err := unmarshalResponse(resp, &docs); err != nil {
 		return DocumentList{}, err
 	}
 
 	return docs, nil
 }
 
-// Path gets the path of the NamedView relative to the database root.
-func (v NamedView) Path() string {
-	return fmt.Sprintf("_design/%s/_view/%s", v.DesignDoc, v.Name)
+// viewRequest sends a query to the view at the provided path. When keys are provided they
+// are always sent as the body of a POST request because the number of keys can easily make
+// the URL too long. On CouchDB 3+ any other query which would result in an overly long URL
+// is also sent as a POST, with all of the parameters included in the body. Earlier servers
+// (before 2.2) ignore any parameters in the body other than keys, so the query is left in
+// the URL when the server may not support them.
+func (d *Database) viewRequest(path string, params ViewParams, doTimeout bool) (*http.Response, error) {
+	params, err := params.forVersion(d.con.version)
+	if err != nil {
+		return nil, err
+	}
+
+	if path, err = d.viewPartitionPath(path, params.Partition); err != nil {
+		return nil, err
+	}
+
+	var body interface{}
+
+	if params.Keys != nil {
+		body = map[string]interface{}{"keys": params.Keys}
+		params.Keys = nil
+	}
+
+	opts, err := params.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	vurl := d.con.URL(path)
+	vurl.RawQuery = opts.Encode()
+
+	if body == nil {
+		// The major version is not enough to tell 2.0 & 2.1 apart from 2.2+
+		if len(vurl.String()) <= maxViewURLLength || d.con.version < 3 {
+			return d.con.urlRequest("GET", vurl, opts, nil, nil, doTimeout)
+		}
+
+		body = params.bodyValues()
+		opts = url.Values{}
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.con.urlRequest("POST", vurl, opts, nil, bytes.NewBuffer(b), doTimeout)
 }
 
-// FullPath gets the path of the NamedView relative to the server root.
-func (v NamedView) FullPath() string {
-	return urlConcat(v.db.Name(), v.Path())
+// queryViewMany sends several queries to the view at the provided path using the queries
+// endpoint. If the server does not support the endpoint then each query is instead run
+// individually.
+func (d *Database) queryViewMany(path string, params []ViewParams) ([]DocumentList, error) {
+	queries := make([]map[string]interface{}, 0, len(params))
+	for _, p := range params {
+		p, err := p.forVersion(d.con.version)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.Partition != params[0].Partition {
+			return nil, errors.New("all queries must use the same partition")
+		}
+
+		queries = append(queries, p.bodyValues())
+	}
+
+	if len(params) > 0 {
+		var err error
+		if path, err = d.viewPartitionPath(path, params[0].Partition); err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"queries": queries})
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Results []DocumentList `json:"results"`
+	}
+
+	_, err = d.con.unmarshalRequest("POST", urlConcat(path, "queries"), NewURLOptions(), bytes.NewBuffer(b), &res)
+	switch {
+	case err == nil:
+		return res.Results, nil
+	case ErrorStatus(err, 400), ErrorStatus(err, 404), ErrorStatus(err, 405):
+		// Servers before CouchDB 2.2 do not have the queries endpoint
+	default:
+		return nil, err
+	}
+
+	results := make([]DocumentList, 0, len(params))
+	for _, p := range params {
+		docs, err := d.queryView(path, p)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, docs)
+	}
+
+	return results, nil
 }
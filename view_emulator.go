@@ -0,0 +1,338 @@
+package sofa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MapFunc is the Go equivalent of a view map function. It is called once for each document
+// and calls emit for each row which should be included in the view.
+type MapFunc func(doc map[string]interface{}, emit func(key, value interface{}))
+
+// ReduceFunc is the Go equivalent of a view reduce function. The keys are [key, id] pairs
+// for each of the values being reduced. EmulatedView always reduces all of the values in a
+// group at once so rereduce is always false, but it is included so that the same functions
+// can mirror their JavaScript equivalents.
+type ReduceFunc func(keys []interface{}, values []interface{}, rereduce bool) interface{}
+
+// CountReduce is a ReduceFunc which is equivalent to the built-in _count reduce function.
+func CountReduce(keys []interface{}, values []interface{}, rereduce bool) interface{} {
+	return float64(len(values))
+}
+
+// SumReduce is a ReduceFunc which is equivalent to the built-in _sum reduce function for
+// numeric values. Values which are not numbers are ignored.
+func SumReduce(keys []interface{}, values []interface{}, rereduce bool) interface{} {
+	var sum float64
+	for _, v := range values {
+		if f, ok := v.(float64); ok {
+			sum += f
+		}
+	}
+
+	return sum
+}
+
+// EmulatedView runs Go map & reduce functions over a set of documents in-process, returning
+// the same results as CouchDB would for an equivalent view. It is intended for testing code
+// which uses views without needing a CouchDB server:
+//
+//	view := &EmulatedView{
+//		Map: func(doc map[string]interface{}, emit func(key, value interface{})) {
+//			emit(doc["type"], 1)
+//		},
+//		Reduce: CountReduce,
+//	}
+//	view.AddDocuments(fruit...)
+//	counts, err := view.Execute(ViewParams{Group: True})
+//
+// The ViewParams key ranges & keys, descending, inclusive_end, limit, skip, reduce, group,
+// group_level & include_docs are all supported. Rows are sorted using CouchDB collation.
+type EmulatedView struct {
+	Map    MapFunc
+	Reduce ReduceFunc
+
+	docs map[string]json.RawMessage
+}
+
+// AddDocuments adds documents to the set of documents which the view is run over. Each
+// document is converted to JSON & must have a string _id field. A document with the same ID
+// as an existing document replaces it.
+func (v *EmulatedView) AddDocuments(docs ...interface{}) error {
+	if v.docs == nil {
+		v.docs = map[string]json.RawMessage{}
+	}
+
+	for _, doc := range docs {
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+
+		var meta struct {
+			ID string `json:"_id"`
+		}
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return err
+		}
+
+		if meta.ID == "" {
+			return errors.New("emulated view documents must have an _id")
+		}
+
+		v.docs[meta.ID] = b
+	}
+
+	return nil
+}
+
+// Execute runs the view over the documents using the provided ViewParams.
+func (v *EmulatedView) Execute(params ViewParams) (DocumentList, error) {
+	if v.Map == nil {
+		return DocumentList{}, errors.New("emulated view has no map function")
+	}
+
+	params, err := params.forVersion(0)
+	if err != nil {
+		return DocumentList{}, err
+	}
+
+	reduce := v.Reduce != nil && params.Reduce != False
+	grouped := params.Group == True || params.GroupLevel > 0
+
+	switch {
+	case grouped && !reduce:
+		return DocumentList{}, errors.New("group & group_level are only valid for reduce views")
+	case reduce && params.IncludeDocs == True:
+		return DocumentList{}, errors.New("include_docs is not valid for reduce views")
+	}
+
+	rows, err := v.mapRows()
+	if err != nil {
+		return DocumentList{}, err
+	}
+
+	descending := params.Descending == True
+	if descending {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	total := len(rows)
+	offset, selected := selectRows(rows, params, descending)
+
+	if reduce {
+		selected = reduceRows(selected, v.Reduce, params)
+	} else if params.IncludeDocs == True {
+		for i := range selected {
+			selected[i].Document = v.rowDocument(selected[i])
+		}
+	}
+
+	if skip := int(params.Skip); skip > 0 {
+		if skip > len(selected) {
+			skip = len(selected)
+		}
+		selected = selected[skip:]
+		offset += skip
+	}
+
+	if limit := int(params.Limit); limit > 0 && limit < len(selected) {
+		selected = selected[:limit]
+	}
+
+	if reduce {
+		return DocumentList{Rows: selected}, nil
+	}
+
+	return DocumentList{
+		TotalRows: float64(total),
+		Offset:    float64(offset),
+		Rows:      selected,
+	}, nil
+}
+
+// mapRows runs the map function over each document in ID order and returns the emitted
+// rows sorted by key & then by the raw bytes of the document ID, as CouchDB does.
+func (v *EmulatedView) mapRows() ([]Row, error) {
+	ids := make([]string, 0, len(v.docs))
+	for id := range v.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	rows := []Row{}
+	for _, id := range ids {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(v.docs[id], &doc); err != nil {
+			return nil, err
+		}
+
+		var emitErr error
+		v.Map(doc, func(key, value interface{}) {
+			k, err := emulatedValue(key)
+			if err != nil {
+				emitErr = err
+				return
+			}
+
+			val, err := emulatedValue(value)
+			if err != nil {
+				emitErr = err
+				return
+			}
+
+			rows = append(rows, Row{ID: id, Key: k, Value: val})
+		})
+
+		if emitErr != nil {
+			return nil, fmt.Errorf("emit failed for document %q: %v", id, emitErr)
+		}
+	}
+
+	SortRows(rows, false)
+	return rows, nil
+}
+
+// emulatedValue converts an emitted key or value into the value which would be returned
+// from CouchDB, which is the result of encoding it as JSON.
+func emulatedValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
+
+// selectRows returns the rows selected by the keys & key ranges in the params, along with
+// the offset of the first selected row. The rows must already be in the order requested.
+func selectRows(rows []Row, params ViewParams, descending bool) (int, []Row) {
+	if params.Keys != nil {
+		selected := []Row{}
+		for _, key := range params.Keys.innerVal {
+			for _, row := range rows {
+				if Collate(row.Key, key) == 0 {
+					selected = append(selected, row)
+				}
+			}
+		}
+		return 0, selected
+	}
+
+	// position compares a row with a key & optional document ID in the order of the rows.
+	position := func(row Row, key interface{}, docID string) int {
+		c := Collate(row.Key, key)
+		if c == 0 && docID != "" {
+			c = strings.Compare(row.ID, docID)
+		}
+		if descending {
+			c = -c
+		}
+		return c
+	}
+
+	offset := -1
+	selected := []Row{}
+	for i, row := range rows {
+		if params.Key != nil && Collate(row.Key, params.Key) != 0 {
+			continue
+		}
+
+		if params.StartKey != nil && position(row, params.StartKey, params.StartKeyDocID) < 0 {
+			continue
+		}
+
+		if params.EndKey != nil {
+			c := position(row, params.EndKey, params.EndKeyDocID)
+			if c > 0 || (c == 0 && params.InclusiveEnd == False) {
+				continue
+			}
+		}
+
+		if offset < 0 {
+			offset = i
+		}
+		selected = append(selected, row)
+	}
+
+	if offset < 0 {
+		offset = len(rows)
+	}
+
+	return offset, selected
+}
+
+// reduceRows reduces the rows into a single row, or one row for each group if grouping
+// was requested.
+func reduceRows(rows []Row, fn ReduceFunc, params ViewParams) []Row {
+	groupKey := func(key interface{}) interface{} {
+		if params.Group == True && params.GroupLevel == 0 {
+			return key
+		}
+
+		if arr, ok := key.([]interface{}); ok && len(arr) > int(params.GroupLevel) {
+			return arr[:int(params.GroupLevel)]
+		}
+
+		return key
+	}
+
+	grouped := params.Group == True || params.GroupLevel > 0
+	if !grouped {
+		if len(rows) == 0 {
+			return []Row{}
+		}
+		return []Row{{Value: reduceGroup(rows, fn)}}
+	}
+
+	reduced := []Row{}
+	for start := 0; start < len(rows); {
+		key := groupKey(rows[start].Key)
+
+		end := start + 1
+		for end < len(rows) && Collate(groupKey(rows[end].Key), key) == 0 {
+			end++
+		}
+
+		reduced = append(reduced, Row{Key: key, Value: reduceGroup(rows[start:end], fn)})
+		start = end
+	}
+
+	return reduced
+}
+
+func reduceGroup(rows []Row, fn ReduceFunc) interface{} {
+	keys := make([]interface{}, 0, len(rows))
+	values := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, []interface{}{row.Key, row.ID})
+		values = append(values, row.Value)
+	}
+
+	return fn(keys, values, false)
+}
+
+// rowDocument returns the document to include with a row when include_docs is used. If the
+// value of the row is an object with an _id then the document with that ID is included
+// instead, as CouchDB does for linked documents.
+func (v *EmulatedView) rowDocument(row Row) json.RawMessage {
+	id := row.ID
+	if val, ok := row.Value.(map[string]interface{}); ok {
+		if linked, ok := val["_id"].(string); ok {
+			id = linked
+		}
+	}
+
+	if doc, ok := v.docs[id]; ok {
+		return doc
+	}
+
+	return json.RawMessage("null")
+}
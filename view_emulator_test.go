@@ -0,0 +1,148 @@
+package sofa
+
+import (
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func newFruitEmulatedView(t *testing.T) *EmulatedView {
+	view := &EmulatedView{
+		Map: func(doc map[string]interface{}, emit func(key, value interface{})) {
+			if doc["type"] != "fruit" {
+				return
+			}
+			emit([]interface{}{doc["colour"], doc["name"]}, doc["price"])
+		},
+		Reduce: SumReduce,
+	}
+
+	st.Assert(t, view.AddDocuments(
+		map[string]interface{}{"_id": "apple", "type": "fruit", "colour": "green", "name": "apple", "price": 2},
+		map[string]interface{}{"_id": "banana", "type": "fruit", "colour": "yellow", "name": "banana", "price": 1},
+		map[string]interface{}{"_id": "kiwi", "type": "fruit", "colour": "green", "name": "kiwi", "price": 3},
+		map[string]interface{}{"_id": "lemon", "type": "fruit", "colour": "yellow", "name": "Lemon", "price": 4},
+		map[string]interface{}{"_id": "carrot", "type": "vegetable", "colour": "orange", "name": "carrot", "price": 1},
+	), nil)
+
+	return view
+}
+
+func rowIDs(rows []Row) []string {
+	ids := []string{}
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return ids
+}
+
+func TestEmulatedViewMap(t *testing.T) {
+	view := newFruitEmulatedView(t)
+
+	docs, err := view.Execute(ViewParams{Reduce: False})
+	st.Assert(t, err, nil)
+	st.Assert(t, docs.TotalRows, float64(4))
+	st.Assert(t, rowIDs(docs.Rows), []string{"apple", "kiwi", "banana", "lemon"})
+	st.Assert(t, docs.Rows[0].Key, []interface{}{"green", "apple"})
+	st.Assert(t, docs.Rows[0].Value, float64(2))
+
+	docs, err = view.Execute(ViewParams{
+		Reduce:   False,
+		StartKey: NewInterfaceParameter([]interface{}{"yellow"}),
+		EndKey:   NewInterfaceParameter([]interface{}{"yellow", map[string]interface{}{}}),
+	})
+	st.Assert(t, err, nil)
+	st.Assert(t, docs.Offset, float64(2))
+	st.Assert(t, rowIDs(docs.Rows), []string{"banana", "lemon"})
+
+	docs, err = view.Execute(ViewParams{
+		Reduce:       False,
+		Descending:   True,
+		StartKey:     NewInterfaceParameter([]interface{}{"yellow", "Lemon"}),
+		EndKey:       NewInterfaceParameter([]interface{}{"green", "apple"}),
+		InclusiveEnd: False,
+		Skip:         1,
+		Limit:        1,
+	})
+	st.Assert(t, err, nil)
+	st.Assert(t, docs.Offset, float64(1))
+	st.Assert(t, rowIDs(docs.Rows), []string{"banana"})
+
+	docs, err = view.Execute(ViewParams{
+		Reduce:      False,
+		Keys:        NewInterfaceListParameter([]interface{}{[]interface{}{"green", "kiwi"}, []interface{}{"green", "apple"}}),
+		IncludeDocs: True,
+	})
+	st.Assert(t, err, nil)
+	st.Assert(t, rowIDs(docs.Rows), []string{"kiwi", "apple"})
+
+	var fruit []map[string]interface{}
+	fruit, err = docs.MapDocuments()
+	st.Assert(t, err, nil)
+	st.Assert(t, fruit[0]["name"], "kiwi")
+}
+
+func TestEmulatedViewReduce(t *testing.T) {
+	view := newFruitEmulatedView(t)
+
+	docs, err := view.Execute(ViewParams{})
+	st.Assert(t, err, nil)
+	st.Assert(t, len(docs.Rows), 1)
+	st.Assert(t, docs.Rows[0].Key, nil)
+	st.Assert(t, docs.Rows[0].Value, float64(10))
+
+	docs, err = view.Execute(ViewParams{GroupLevel: 1})
+	st.Assert(t, err, nil)
+	st.Assert(t, len(docs.Rows), 2)
+	st.Assert(t, docs.Rows[0].Key, []interface{}{"green"})
+	st.Assert(t, docs.Rows[0].Value, float64(5))
+	st.Assert(t, docs.Rows[1].Key, []interface{}{"yellow"})
+	st.Assert(t, docs.Rows[1].Value, float64(5))
+
+	docs, err = view.Execute(ViewParams{Group: True, Limit: 1, Skip: 3})
+	st.Assert(t, err, nil)
+	st.Assert(t, docs.Rows[0].Key, []interface{}{"yellow", "Lemon"})
+	st.Assert(t, docs.Rows[0].Value, float64(4))
+
+	view.Reduce = CountReduce
+	docs, err = view.Execute(ViewParams{Key: NewInterfaceParameter([]interface{}{"green", "kiwi"})})
+	st.Assert(t, err, nil)
+	st.Assert(t, docs.Rows[0].Value, float64(1))
+
+	_, err = view.Execute(ViewParams{IncludeDocs: True})
+	st.Reject(t, err, nil)
+
+	view.Reduce = nil
+	_, err = view.Execute(ViewParams{Group: True})
+	st.Reject(t, err, nil)
+}
+
+func TestEmulatedViewDocumentIDs(t *testing.T) {
+	view := &EmulatedView{
+		Map: func(doc map[string]interface{}, emit func(key, value interface{})) {
+			emit(doc["type"], nil)
+		},
+	}
+
+	st.Assert(t, view.AddDocuments(
+		map[string]interface{}{"_id": "a", "type": "fruit"},
+		map[string]interface{}{"_id": "B", "type": "fruit"},
+		map[string]interface{}{"_id": "b", "type": "fruit"},
+	), nil)
+
+	// Document IDs are compared by their raw bytes so "B" sorts before "a"
+	docs, err := view.Execute(ViewParams{})
+	st.Assert(t, err, nil)
+	st.Assert(t, rowIDs(docs.Rows), []string{"B", "a", "b"})
+
+	docs, err = view.Execute(ViewParams{
+		StartKey:      NewInterfaceParameter("fruit"),
+		StartKeyDocID: "a",
+		EndKey:        NewInterfaceParameter("fruit"),
+		EndKeyDocID:   "b",
+		InclusiveEnd:  False,
+	})
+	st.Assert(t, err, nil)
+	st.Assert(t, docs.Offset, float64(1))
+	st.Assert(t, rowIDs(docs.Rows), []string{"a"})
+}
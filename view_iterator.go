@@ -0,0 +1,213 @@
+package sofa
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// pagedView is implemented by the views which can be paged through by a ViewIterator.
+type pagedView interface {
+	Execute(ViewParams) (DocumentList, error)
+}
+
+// ViewIterator pages through the rows of a view, requesting a fixed number of rows at a
+// time. Each page starts from the key & document ID of the first row not included in the
+// previous page rather than using skip, so paging remains fast for large views. Use Next
+// to advance to each row in turn, Row to get the current row and Err to check for errors
+// once Next returns false:
+//
+//	it := db.NamedView("things", "byType").Iterator(ViewParams{}, 100)
+//	for it.Next() {
+//		row := it.Row()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type ViewIterator struct {
+	view     pagedView
+	params   ViewParams
+	pageSize int
+
+	rows  []Row
+	pos   int
+	count int
+	err   error
+
+	// next is the start of the next page, or nil when there are no more pages.
+	next    *viewCursor
+	fetched bool
+}
+
+// viewCursor is the position of a row in a view, used as the start of a page.
+type viewCursor struct {
+	Key   interface{} `json:"k"`
+	DocID string      `json:"d,omitempty"`
+}
+
+// Iterator creates a ViewIterator which returns the rows of the NamedView matching the
+// provided ViewParams, fetching pageSize rows in each request. A Limit in the ViewParams
+// limits the total number of rows returned by the iterator.
+func (v NamedView) Iterator(params ViewParams, pageSize int) *ViewIterator {
+	return newViewIterator(v, params, pageSize)
+}
+
+// Iterator creates a ViewIterator over the rows of _all_docs in the same way as
+// NamedView.Iterator.
+func (v AllDocsView) Iterator(params ViewParams, pageSize int) *ViewIterator {
+	return newViewIterator(v, params, pageSize)
+}
+
+func newViewIterator(view pagedView, params ViewParams, pageSize int) *ViewIterator {
+	params, err := params.forVersion(0)
+
+	it := &ViewIterator{
+		view:     view,
+		params:   params,
+		pageSize: pageSize,
+		pos:      -1,
+	}
+
+	// A single key is equivalent to a range which starts & ends at that key, which
+	// allows the start of each page to be moved along as normal.
+	if params.Key != nil {
+		it.params.StartKey = params.Key
+		it.params.EndKey = params.Key
+		it.params.Key = nil
+	}
+
+	switch {
+	case err != nil:
+		it.err = err
+	case pageSize < 1:
+		it.err = fmt.Errorf("invalid view iterator page size: %d", pageSize)
+	case params.Keys != nil:
+		it.err = errors.New("cannot page through a view queried with keys")
+	}
+
+	return it
+}
+
+// Resume moves the iterator to the position described by a cursor previously returned
+// from Cursor. It must be called before the first call to Next and the iterator must have
+// been created with the same ViewParams as the one which returned the cursor.
+func (it *ViewIterator) Resume(cursor string) error {
+	if it.fetched {
+		return errors.New("cannot resume a view iterator which has already started")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("invalid view cursor: %v", err)
+	}
+
+	var c viewCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return fmt.Errorf("invalid view cursor: %v", err)
+	}
+
+	it.next = &c
+	return nil
+}
+
+// Next advances the iterator to the next row, fetching the next page of rows from the
+// server if required. It returns false when there are no more rows or an error occurs.
+func (it *ViewIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.params.Limit > 0 && float64(it.count) >= it.params.Limit {
+		return false
+	}
+
+	if it.pos+1 >= len(it.rows) {
+		if it.fetched && it.next == nil {
+			return false
+		}
+
+		if err := it.fetchPage(); err != nil {
+			it.err = err
+			return false
+		}
+
+		if len(it.rows) == 0 {
+			return false
+		}
+	}
+
+	it.pos++
+	it.count++
+	return true
+}
+
+// fetchPage requests the next page of rows, including one extra row which is used as the
+// start of the following page.
+func (it *ViewIterator) fetchPage() error {
+	params := it.params
+	params.Limit = float64(it.pageSize + 1)
+
+	if it.next != nil {
+		params.StartKey = NewInterfaceParameter(it.next.Key)
+		params.StartKeyDocID = it.next.DocID
+		params.Skip = 0
+	}
+
+	docs, err := it.view.Execute(params)
+	if err != nil {
+		return err
+	}
+
+	rows := docs.Rows
+	if len(rows) > it.pageSize {
+		it.next = &viewCursor{Key: rows[it.pageSize].Key, DocID: rows[it.pageSize].ID}
+		rows = rows[:it.pageSize]
+	} else {
+		it.next = nil
+	}
+
+	it.rows = rows
+	it.pos = -1
+	it.fetched = true
+
+	return nil
+}
+
+// Row returns the current row of the iterator.
+func (it *ViewIterator) Row() Row {
+	if it.pos < 0 || it.pos >= len(it.rows) {
+		return Row{}
+	}
+
+	return it.rows[it.pos]
+}
+
+// Err returns the error which caused Next to return false, if there was one.
+func (it *ViewIterator) Err() error {
+	return it.err
+}
+
+// Cursor returns an opaque token describing the position after the current row which can
+// be passed to Resume to continue iterating from the same place later, for example by a
+// client of an API which pages through a view. An empty string is returned when there are
+// no more rows.
+func (it *ViewIterator) Cursor() (string, error) {
+	next := it.next
+	if it.pos+1 < len(it.rows) {
+		row := it.rows[it.pos+1]
+		next = &viewCursor{Key: row.Key, DocID: row.ID}
+	}
+
+	if next == nil {
+		return "", nil
+	}
+
+	b, err := json.Marshal(next)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
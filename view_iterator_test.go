@@ -0,0 +1,106 @@
+package sofa
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func mockViewIteratorPage(startKey string, ids ...string) {
+	rows := []map[string]interface{}{}
+	for _, id := range ids {
+		rows = append(rows, map[string]interface{}{
+			"id":    id,
+			"key":   id,
+			"value": map[string]string{"rev": DefaultFirstRev},
+		})
+	}
+
+	req := gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/iterator_test_db/_all_docs").
+		MatchParam("limit", "^3$")
+
+	if startKey != "" {
+		req = req.MatchParam("startkey", fmt.Sprintf(`^"%s"$`, startKey)).
+			MatchParam("startkey_docid", fmt.Sprintf("^%s$", startKey))
+	}
+
+	req.Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 5,
+			"offset":     0,
+			"rows":       rows,
+		})
+}
+
+func TestViewIterator(t *testing.T) {
+	defer gock.Off()
+
+	mockViewIteratorPage("", "a", "b", "c")
+	mockViewIteratorPage("c", "c", "d", "e")
+	mockViewIteratorPage("e", "e")
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("iterator_test_db")
+
+	it := db.AllDocsView().Iterator(ViewParams{}, 2)
+
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Row().ID)
+	}
+	st.Assert(t, it.Err(), nil)
+
+	st.Assert(t, ids, []string{"a", "b", "c", "d", "e"})
+	st.Assert(t, gock.IsDone(), true)
+
+	cursor, err := it.Cursor()
+	st.Assert(t, err, nil)
+	st.Assert(t, cursor, "")
+}
+
+func TestViewIteratorResume(t *testing.T) {
+	defer gock.Off()
+
+	mockViewIteratorPage("", "a", "b", "c")
+	mockViewIteratorPage("b", "b", "c", "d")
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("iterator_test_db")
+
+	first := db.AllDocsView().Iterator(ViewParams{Limit: 1}, 2)
+	st.Assert(t, first.Next(), true)
+	st.Assert(t, first.Row().ID, "a")
+	st.Assert(t, first.Next(), false)
+	st.Assert(t, first.Err(), nil)
+
+	cursor, err := first.Cursor()
+	st.Assert(t, err, nil)
+
+	second := db.AllDocsView().Iterator(ViewParams{}, 2)
+	st.Assert(t, second.Resume(cursor), nil)
+
+	st.Assert(t, second.Next(), true)
+	st.Assert(t, second.Row().ID, "b")
+	st.Assert(t, second.Next(), true)
+	st.Assert(t, second.Row().ID, "c")
+	st.Assert(t, gock.IsDone(), true)
+
+	st.Reject(t, second.Resume(cursor), nil)
+	st.Reject(t, db.AllDocsView().Iterator(ViewParams{}, 2).Resume("not a cursor"), nil)
+}
+
+func TestViewIteratorInvalid(t *testing.T) {
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("iterator_test_db")
+
+	keys := db.NamedView("things", "byType").Iterator(ViewParams{Keys: NewInterfaceListParameter([]interface{}{"a"})}, 10)
+	st.Assert(t, keys.Next(), false)
+	st.Reject(t, keys.Err(), nil)
+
+	size := db.AllDocsView().Iterator(ViewParams{}, 0)
+	st.Assert(t, size.Next(), false)
+	st.Reject(t, size.Err(), nil)
+}
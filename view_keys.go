@@ -0,0 +1,104 @@
+package sofa
+
+import (
+	"errors"
+)
+
+// highStringSuffix is appended to a string to get a key which sorts after every string
+// beginning with it.
+const highStringSuffix = "\ufff0"
+
+// CompoundKey creates a compound (array) view key from its parts. It is equivalent to
+// []interface{}{parts...} but reads better when building keys.
+func CompoundKey(parts ...interface{}) []interface{} {
+	if parts == nil {
+		return []interface{}{}
+	}
+
+	return parts
+}
+
+// KeyRange is a range of view keys which can be applied to ViewParams. A KeyRange is always
+// described from the lowest key to the highest key and the start & end keys are swapped
+// automatically when it is applied to a descending query.
+type KeyRange struct {
+	low, high   interface{}
+	exact       bool
+	excludeHigh bool
+}
+
+// ExactKey creates a KeyRange which matches a single key.
+func ExactKey(key interface{}) KeyRange {
+	return KeyRange{low: key, high: key, exact: true}
+}
+
+// KeyBetween creates a KeyRange which matches every key from low to high, including both
+// low & high themselves.
+func KeyBetween(low, high interface{}) KeyRange {
+	return KeyRange{low: low, high: high}
+}
+
+// KeyPrefix creates a KeyRange which matches every compound key which begins with the
+// provided parts, for example KeyPrefix("user1") matches ["user1"], ["user1", 2] and
+// ["user1", "a", "b"]. The range is from [parts...] to [parts..., {}], as an empty object
+// sorts after every other value.
+func KeyPrefix(parts ...interface{}) KeyRange {
+	low := append([]interface{}{}, parts...)
+	high := append(append([]interface{}{}, parts...), map[string]interface{}{})
+
+	return KeyRange{low: low, high: high}
+}
+
+// StringPrefix creates a KeyRange which matches every string key which begins with the
+// prefix, using a high key of the prefix followed by "\ufff0".
+func StringPrefix(prefix string) KeyRange {
+	return KeyRange{low: prefix, high: prefix + highStringSuffix}
+}
+
+// ExcludeHigh returns a copy of the KeyRange which does not include the high key, using
+// inclusive_end=false. This can only be applied to ascending queries, as CouchDB has no way
+// to exclude the start key of a descending query.
+func (r KeyRange) ExcludeHigh() KeyRange {
+	r.excludeHigh = true
+	return r
+}
+
+// Apply returns a copy of the ViewParams with the key, start & end keys and inclusive_end
+// set to match the KeyRange. If the ViewParams are descending then the start & end keys are
+// swapped so that the same range of keys is matched. Any existing key, keys, start & end
+// keys (including the start_key & end_key aliases) and document IDs used to start or end
+// the range are replaced.
+func (r KeyRange) Apply(params ViewParams) (ViewParams, error) {
+	params.Key = nil
+	params.Keys = nil
+	params.StartKey = nil
+	params.StartKeyAlias = nil
+	params.EndKey = nil
+	params.EndKeyAlias = nil
+	params.StartKeyDocID = ""
+	params.EndKeyDocID = ""
+	params.InclusiveEnd = Empty
+
+	if r.exact {
+		params.Key = NewInterfaceParameter(r.low)
+		return params, nil
+	}
+
+	if params.Descending == True {
+		if r.excludeHigh {
+			return params, errors.New("cannot exclude the high key of a descending key range")
+		}
+
+		params.StartKey = NewInterfaceParameter(r.high)
+		params.EndKey = NewInterfaceParameter(r.low)
+		return params, nil
+	}
+
+	params.StartKey = NewInterfaceParameter(r.low)
+	params.EndKey = NewInterfaceParameter(r.high)
+	if r.excludeHigh {
+		params.InclusiveEnd = False
+	}
+
+	return params, nil
+}
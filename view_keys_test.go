@@ -0,0 +1,89 @@
+package sofa
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func newKeysEmulatedView(t *testing.T) *EmulatedView {
+	view := &EmulatedView{
+		Map: func(doc map[string]interface{}, emit func(key, value interface{})) {
+			emit(doc["key"], nil)
+		},
+	}
+
+	st.Assert(t, view.AddDocuments(
+		map[string]interface{}{"_id": "a", "key": CompoundKey("user1")},
+		map[string]interface{}{"_id": "b", "key": CompoundKey("user1", 1)},
+		map[string]interface{}{"_id": "c", "key": CompoundKey("user1", "x", "y")},
+		map[string]interface{}{"_id": "d", "key": CompoundKey("user10")},
+		map[string]interface{}{"_id": "e", "key": CompoundKey("user2")},
+		map[string]interface{}{"_id": "f", "key": "apple"},
+		map[string]interface{}{"_id": "g", "key": "apricot"},
+		map[string]interface{}{"_id": "h", "key": "banana"},
+	), nil)
+
+	return view
+}
+
+func TestKeyRangeValues(t *testing.T) {
+	params, err := KeyPrefix("user1").Apply(ViewParams{StartKeyDocID: "a", Key: NewInterfaceParameter("x")})
+	st.Assert(t, err, nil)
+
+	values, err := params.Values()
+	st.Assert(t, err, nil)
+	st.Assert(t, values, url.Values{
+		"startkey": []string{`["user1"]`},
+		"endkey":   []string{`["user1",{}]`},
+	})
+
+	params, err = StringPrefix("ap").Apply(ViewParams{Descending: True})
+	st.Assert(t, err, nil)
+
+	values, err = params.Values()
+	st.Assert(t, err, nil)
+	st.Assert(t, values, url.Values{
+		"descending": []string{"true"},
+		"startkey":   []string{`"ap￰"`},
+		"endkey":     []string{`"ap"`},
+	})
+
+	params, err = KeyBetween(1, 5).ExcludeHigh().Apply(ViewParams{})
+	st.Assert(t, err, nil)
+	st.Assert(t, params.InclusiveEnd, False)
+
+	_, err = KeyBetween(1, 5).ExcludeHigh().Apply(ViewParams{Descending: True})
+	st.Reject(t, err, nil)
+}
+
+func TestKeyRangeRows(t *testing.T) {
+	view := newKeysEmulatedView(t)
+
+	tests := []struct {
+		keys     KeyRange
+		params   ViewParams
+		expected []string
+	}{
+		{KeyPrefix("user1"), ViewParams{}, []string{"a", "b", "c"}},
+		{KeyPrefix("user1"), ViewParams{Descending: True}, []string{"c", "b", "a"}},
+		{KeyPrefix("user1", "x"), ViewParams{}, []string{"c"}},
+		{StringPrefix("ap"), ViewParams{}, []string{"f", "g"}},
+		{StringPrefix("ap"), ViewParams{Descending: True}, []string{"g", "f"}},
+		{ExactKey(CompoundKey("user10")), ViewParams{}, []string{"d"}},
+		{KeyBetween(CompoundKey("user1"), CompoundKey("user2")), ViewParams{Descending: True}, []string{"e", "d", "c", "b", "a"}},
+		{KeyBetween(CompoundKey("user1"), CompoundKey("user2")).ExcludeHigh(), ViewParams{}, []string{"a", "b", "c", "d"}},
+		{KeyPrefix("user2"), ViewParams{Keys: NewInterfaceListParameter([]interface{}{CompoundKey("user1")})}, []string{"e"}},
+		{KeyPrefix("user2"), ViewParams{StartKeyAlias: NewInterfaceParameter(CompoundKey("user1")), EndKeyAlias: NewInterfaceParameter(CompoundKey("user1"))}, []string{"e"}},
+	}
+
+	for n, tc := range tests {
+		params, err := tc.keys.Apply(tc.params)
+		st.Expect(t, err, nil, n)
+
+		docs, err := view.Execute(params)
+		st.Expect(t, err, nil, n)
+		st.Expect(t, rowIDs(docs.Rows), tc.expected, n)
+	}
+}
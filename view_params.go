@@ -0,0 +1,113 @@
+package sofa
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validate checks the ViewParams for combinations of parameters which CouchDB would reject
+// or which cannot have the intended effect. It is called automatically before a view is
+// queried but can also be used to check parameters in advance.
+func (v ViewParams) Validate() error {
+	switch {
+	case v.Key != nil && v.Keys != nil:
+		return errors.New("key and keys cannot be used together")
+	case v.Keys != nil && (v.StartKey != nil || v.StartKeyAlias != nil || v.EndKey != nil || v.EndKeyAlias != nil):
+		return errors.New("keys cannot be used with a start or end key")
+	case v.StartKey != nil && v.StartKeyAlias != nil:
+		return errors.New("startkey and start_key cannot be used together")
+	case v.EndKey != nil && v.EndKeyAlias != nil:
+		return errors.New("endkey and end_key cannot be used together")
+	case v.Limit < 0 || v.Skip < 0 || v.GroupLevel < 0:
+		return errors.New("limit, skip and group_level cannot be negative")
+	case v.Reduce == False && (v.Group == True || v.GroupLevel > 0):
+		return errors.New("group and group_level require the view to be reduced")
+	case v.IncludeDocs == True && (v.Reduce == True || v.Group == True || v.GroupLevel > 0):
+		return errors.New("include_docs cannot be used when the view is reduced")
+	case v.Stale != "" && (v.Stable != Empty || v.Update != ""):
+		return errors.New("stale cannot be used with stable or update")
+	}
+
+	switch v.Stale {
+	case "", "ok", "update_after":
+	default:
+		return fmt.Errorf("invalid value for stale: %q", v.Stale)
+	}
+
+	switch v.Update {
+	case "", "true", "false", "lazy":
+	default:
+		return fmt.Errorf("invalid value for update: %q", v.Update)
+	}
+
+	if v.Partition != "" {
+		return validatePartitionName(v.Partition)
+	}
+
+	return nil
+}
+
+// forVersion validates the ViewParams and converts them into the form expected by a server
+// with the provided major version (or 0 if the version is not known). The start_key &
+// end_key aliases are replaced by startkey & endkey, stale is translated to stable & update
+// on CouchDB 3 (where it is deprecated) and stable & update are translated to stale on
+// CouchDB 1 (where they are not supported).
+func (v ViewParams) forVersion(version int) (ViewParams, error) {
+	if err := v.Validate(); err != nil {
+		return ViewParams{}, err
+	}
+
+	if v.StartKeyAlias != nil {
+		v.StartKey, v.StartKeyAlias = v.StartKeyAlias, nil
+	}
+	if v.EndKeyAlias != nil {
+		v.EndKey, v.EndKeyAlias = v.EndKeyAlias, nil
+	}
+
+	switch {
+	case version >= 3 && v.Stale != "":
+		v.Stable = True
+		v.Update = "false"
+		if v.Stale == "update_after" {
+			v.Update = "lazy"
+		}
+		v.Stale = ""
+	case version == 1:
+		if v.Partition != "" {
+			return ViewParams{}, errors.New("partitioned queries require CouchDB 3 or later")
+		}
+
+		switch v.Update {
+		case "false":
+			v.Stale = "ok"
+		case "lazy":
+			v.Stale = "update_after"
+		}
+		v.Update = ""
+		v.Stable = Empty
+	case version == 2 && v.Partition != "":
+		return ViewParams{}, errors.New("partitioned queries require CouchDB 3 or later")
+	}
+
+	return v, nil
+}
+
+// viewPartitionPath returns the path used to query a view within a partition, or the path
+// unchanged if no partition is provided.
+func (d *Database) viewPartitionPath(path, partition string) (string, error) {
+	if partition == "" {
+		return path, nil
+	}
+
+	prefix := d.partitionPath("")
+	if strings.HasPrefix(path, urlConcat(prefix, "_partition/")) {
+		if strings.HasPrefix(path, urlConcat(d.partitionPath(partition), "")) {
+			return path, nil
+		}
+
+		return "", fmt.Errorf("cannot query partition %q using a view from a different partition", partition)
+	}
+
+	return urlConcat(d.partitionPath(partition), strings.TrimPrefix(path, prefix)), nil
+}
@@ -0,0 +1,100 @@
+package sofa
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func TestViewParamsValidate(t *testing.T) {
+	invalid := []ViewParams{
+		{Key: NewInterfaceParameter("a"), Keys: NewInterfaceListParameter([]interface{}{"b"})},
+		{Keys: NewInterfaceListParameter([]interface{}{"b"}), StartKeyAlias: NewInterfaceParameter("a")},
+		{StartKey: NewInterfaceParameter("a"), StartKeyAlias: NewInterfaceParameter("a")},
+		{EndKey: NewInterfaceParameter("a"), EndKeyAlias: NewInterfaceParameter("a")},
+		{Limit: -1},
+		{Reduce: False, GroupLevel: 2},
+		{IncludeDocs: True, Group: True},
+		{IncludeDocs: True, Reduce: True},
+		{Stale: "ok", Update: "false"},
+		{Stale: "later"},
+		{Update: "sometimes"},
+		{Partition: "_design"},
+	}
+
+	for n, params := range invalid {
+		st.Reject(t, params.Validate(), nil, n)
+	}
+
+	valid := []ViewParams{
+		{},
+		{Reduce: False, IncludeDocs: True},
+		{Group: True, GroupLevel: 1},
+		{Stable: True, Update: "lazy"},
+		{Stale: "update_after"},
+		{StartKeyAlias: NewInterfaceParameter("a"), EndKey: NewInterfaceParameter("b")},
+		{Partition: "fruit"},
+	}
+
+	for n, params := range valid {
+		st.Expect(t, params.Validate(), nil, n)
+	}
+}
+
+func TestViewParamsForVersion(t *testing.T) {
+	params, err := ViewParams{Stale: "update_after", StartKeyAlias: NewInterfaceParameter("a")}.forVersion(3)
+	st.Assert(t, err, nil)
+
+	values, err := params.Values()
+	st.Assert(t, err, nil)
+	st.Assert(t, values, url.Values{
+		"stable":   []string{"true"},
+		"update":   []string{"lazy"},
+		"startkey": []string{`"a"`},
+	})
+
+	params, err = ViewParams{Stale: "ok"}.forVersion(2)
+	st.Assert(t, err, nil)
+	st.Assert(t, params.Stale, "ok")
+
+	params, err = ViewParams{Stable: True, Update: "false"}.forVersion(1)
+	st.Assert(t, err, nil)
+	st.Assert(t, params, ViewParams{Stale: "ok"})
+
+	_, err = ViewParams{Partition: "fruit"}.forVersion(2)
+	st.Reject(t, err, nil)
+
+	body := ViewParams{Update: "false", Partition: "fruit"}.bodyValues()
+	st.Assert(t, body, map[string]interface{}{"update": false})
+}
+
+func TestViewParamsVersioned(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Get("/params_test_db/_partition/fruit/_design/fruit/_view/by-name").
+		MatchParam("stable", "true").
+		MatchParam("update", "false").
+		Reply(200).
+		JSON(map[string]interface{}{"total_rows": 0, "offset": 0, "rows": []interface{}{}})
+
+	con := globalTestConnections.Version3(t, true)
+	db := con.Database("params_test_db")
+
+	_, err := db.NamedView("fruit", "by-name").Execute(ViewParams{Stale: "ok", Partition: "fruit"})
+	st.Assert(t, err, nil)
+
+	p, err := db.Partition("vegetable")
+	st.Assert(t, err, nil)
+
+	_, err = p.NamedView("fruit", "by-name").Execute(ViewParams{Partition: "fruit"})
+	st.Reject(t, err, nil)
+
+	_, err = db.NamedView("fruit", "by-name").Execute(ViewParams{Reduce: False, Group: True})
+	st.Reject(t, err, nil)
+
+	st.Assert(t, gock.IsDone(), true)
+}
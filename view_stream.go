@@ -0,0 +1,163 @@
+package sofa
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// RowStream decodes the rows of a view response one at a time as they are read from the
+// server, so that views with too many rows to hold in memory can be processed. The
+// TotalRows, Offset and UpdateSeq fields are filled in as they are found in the response;
+// CouchDB sends them before the rows so they are available after the first call to Next.
+// A RowStream must be closed once it is no longer needed.
+type RowStream struct {
+	TotalRows float64
+	Offset    float64
+	UpdateSeq AlwaysString
+
+	body io.ReadCloser
+	dec  *json.Decoder
+
+	row    Row
+	err    error
+	inRows bool
+	done   bool
+}
+
+// Stream queries the NamedView and returns a RowStream which decodes the rows of the
+// response as they are read. The request is not subject to the connection timeout.
+func (v NamedView) Stream(params ViewParams) (*RowStream, error) {
+	return v.db.streamView(v.queryPath(), params)
+}
+
+// Stream queries _all_docs and returns a RowStream in the same way as NamedView.Stream.
+func (v AllDocsView) Stream(params ViewParams) (*RowStream, error) {
+	return v.db.streamView(v.queryPath(), params)
+}
+
+func (d *Database) streamView(path string, params ViewParams) (*RowStream, error) {
+	resp, err := d.viewRequest(path, params, false)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &RowStream{
+		body: resp.Body,
+		dec:  json.NewDecoder(resp.Body),
+	}
+
+	if err := s.expectDelim('{'); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Next decodes the next row from the response. It returns false when there are no more
+// rows or an error occurs.
+func (s *RowStream) Next() bool {
+	if s.err != nil || s.done {
+		return false
+	}
+
+	if !s.inRows {
+		if s.err = s.readFields(); s.err != nil || s.done {
+			return false
+		}
+	}
+
+	if !s.dec.More() {
+		// Consume the end of the rows array and any fields which follow it
+		if s.err = s.expectDelim(']'); s.err == nil {
+			s.inRows = false
+			s.err = s.readFields()
+		}
+		s.done = true
+		return false
+	}
+
+	s.row = Row{}
+	if s.err = s.dec.Decode(&s.row); s.err != nil {
+		return false
+	}
+
+	return true
+}
+
+// readFields reads the fields of the response object until either the start of the rows
+// array or the end of the object is found.
+func (s *RowStream) readFields() error {
+	for s.dec.More() {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case "rows":
+			s.inRows = true
+			return s.expectDelim('[')
+		case "total_rows":
+			err = s.dec.Decode(&s.TotalRows)
+		case "offset":
+			err = s.dec.Decode(&s.Offset)
+		case "update_seq":
+			err = s.dec.Decode(&s.UpdateSeq)
+		default:
+			var skip json.RawMessage
+			err = s.dec.Decode(&skip)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	s.done = true
+	return s.expectDelim('}')
+}
+
+// expectDelim reads the next token and checks that it is the expected delimiter.
+func (s *RowStream) expectDelim(delim json.Delim) error {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if tok != delim {
+		return fmt.Errorf("unexpected token in view response: expected %v but found %v", delim, tok)
+	}
+
+	return nil
+}
+
+// Row returns the row decoded by the last call to Next.
+func (s *RowStream) Row() Row {
+	return s.row
+}
+
+// Err returns the error which caused Next to return false, if there was one.
+func (s *RowStream) Err() error {
+	return s.err
+}
+
+// Each calls fn for every remaining row in the stream, stopping early if fn returns
+// an error. The stream is closed once all of the rows have been processed.
+func (s *RowStream) Each(fn func(Row) error) error {
+	defer s.Close()
+
+	for s.Next() {
+		if err := fn(s.Row()); err != nil {
+			return err
+		}
+	}
+
+	return s.Err()
+}
+
+// Close closes the response body. Any rows which have not been read are discarded.
+func (s *RowStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,104 @@
+package sofa
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/nbio/st"
+)
+
+func TestNamedViewStream(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/view_test_db/_design/things/_view/byType").
+		MatchParam("update_seq", "true").
+		Reply(200).
+		BodyString(`{"total_rows":3,"update_seq":"12-g1AAAA","offset":1,"rows":[
+{"id":"fruit1","key":"fruit","value":"apple"},
+{"id":"fruit2","key":"fruit","value":"kiwi"}
+],"extra":{"ignored":true}}`)
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("view_test_db")
+
+	stream, err := db.NamedView("things", "byType").Stream(ViewParams{UpdateSeq: True})
+	st.Assert(t, err, nil)
+	defer stream.Close()
+
+	st.Assert(t, stream.Next(), true)
+	st.Assert(t, stream.TotalRows, float64(3))
+	st.Assert(t, stream.Offset, float64(1))
+	st.Assert(t, stream.UpdateSeq, AlwaysString("12-g1AAAA"))
+	st.Assert(t, stream.Row().ID, "fruit1")
+
+	st.Assert(t, stream.Next(), true)
+	st.Assert(t, stream.Row().Value, "kiwi")
+
+	st.Assert(t, stream.Next(), false)
+	st.Assert(t, stream.Err(), nil)
+	st.Assert(t, stream.Next(), false)
+}
+
+func TestAllDocsViewStreamEach(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version1MockHost)).
+		Get("/view_test_db/_all_docs").
+		Reply(200).
+		BodyString(`{"total_rows":2,"offset":0,"update_seq":5,"rows":[{"id":"a","key":"a","value":{"rev":"1-a"}},{"id":"b","key":"b","value":{"rev":"1-b"}}]}`)
+
+	con := globalTestConnections.Version1(t, true)
+	db := con.Database("view_test_db")
+
+	stream, err := db.AllDocsView().Stream(ViewParams{})
+	st.Assert(t, err, nil)
+
+	var ids []string
+	err = stream.Each(func(row Row) error {
+		ids = append(ids, row.ID)
+		return nil
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, ids, []string{"a", "b"})
+	st.Assert(t, stream.UpdateSeq, AlwaysString("5"))
+}
+
+func TestViewStreamErrors(t *testing.T) {
+	defer gock.Off()
+
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version1MockHost)
+
+	gock.New(host).
+		Get("/view_test_db/_all_docs").
+		Reply(200).
+		BodyString(`{"total_rows":2,"offset":0,"rows":[{"id":"a","key":"a"},`)
+
+	gock.New(host).
+		Get("/view_test_db/_all_docs").
+		Reply(200).
+		BodyString(`{"total_rows":2,"offset":0,"rows":[{"id":"a","key":"a"},{"id":"b","key":"b"}]}`)
+
+	con := globalTestConnections.Version1(t, true)
+	db := con.Database("view_test_db")
+
+	truncated, err := db.AllDocsView().Stream(ViewParams{})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, truncated.Next(), true)
+	st.Assert(t, truncated.Next(), false)
+	st.Reject(t, truncated.Err(), nil)
+	truncated.Close()
+
+	stopped, err := db.AllDocsView().Stream(ViewParams{})
+	st.Assert(t, err, nil)
+
+	stop := errors.New("stop")
+	err = stopped.Each(func(row Row) error {
+		return stop
+	})
+	st.Assert(t, err, stop)
+}
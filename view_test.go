@@ -1,7 +1,11 @@
 package sofa
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/nbio/st"
@@ -50,6 +54,124 @@ func TestTemporaryView(t *testing.T) {
 	st.Assert(t, result.Offset, float64(0))
 }
 
+func mockTemporaryViewDesign(host string, deleteStatus, cleanupStatus int) {
+	designPath := "/view_test_db/_design/sofa_temp_[0-9a-f]{32}"
+
+	gock.New(host).
+		Head(designPath).
+		Reply(404)
+
+	gock.New(host).
+		Put(designPath).
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			var design map[string]interface{}
+			if err := json.NewDecoder(req.Body).Decode(&design); err != nil {
+				return false, err
+			}
+			options, _ := design["options"].(map[string]interface{})
+			if options["partitioned"] != false {
+				return false, nil
+			}
+
+			return strings.HasSuffix(req.URL.Path, strings.TrimPrefix(design["_id"].(string), "_design")), nil
+		}).
+		Reply(201).
+		SetHeader("Etag", `"`+DefaultFirstRev+`"`).
+		JSON(map[string]interface{}{"ok": true, "rev": DefaultFirstRev})
+
+	gock.New(host).
+		Get(designPath+"/_view/temp").
+		MatchParam("reduce", "false").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 1,
+			"offset":     0,
+			"rows": []map[string]interface{}{
+				{"id": "fruit1", "key": "fruit", "value": nil},
+			},
+		})
+
+	gock.New(host).
+		Delete(designPath).
+		MatchParam("rev", DefaultFirstRev).
+		Reply(deleteStatus).
+		JSON(map[string]interface{}{"ok": true})
+
+	if deleteStatus >= 300 {
+		return
+	}
+
+	gock.New(host).
+		Post("/view_test_db/_view_cleanup").
+		Reply(cleanupStatus).
+		JSON(map[string]interface{}{"ok": true})
+}
+
+func TestTemporaryViewDesign(t *testing.T) {
+	defer gock.Off()
+
+	mockTemporaryViewDesign(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost), 200, 202)
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("view_test_db")
+
+	result, err := db.TemporaryView("function(doc) { emit(doc.type) }").Execute(ViewParams{Reduce: False})
+	st.Assert(t, err, nil)
+	st.Assert(t, result.Rows[0].ID, "fruit1")
+	st.Assert(t, gock.IsDone(), true)
+}
+
+func TestTemporaryViewDesignCleanup(t *testing.T) {
+	defer gock.Off()
+
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("view_test_db")
+	view := db.TemporaryView("function(doc) { emit(doc.type) }")
+
+	// Failing to remove the index files does not affect the result
+	mockTemporaryViewDesign(host, 200, 403)
+
+	result, err := view.Execute(ViewParams{Reduce: False})
+	st.Assert(t, err, nil)
+	st.Assert(t, len(result.Rows), 1)
+	st.Assert(t, gock.IsDone(), true)
+
+	// Failing to delete the design document is reported alongside the results
+	mockTemporaryViewDesign(host, 409, 0)
+
+	result, err = view.Execute(ViewParams{Reduce: False})
+	st.Assert(t, len(result.Rows), 1)
+	st.Assert(t, result.Rows[0].ID, "fruit1")
+
+	var cerr CleanupError
+	st.Assert(t, errors.As(err, &cerr), true)
+	assertPrefix(t, cerr.DesignDoc, "_design/sofa_temp_")
+	st.Assert(t, ErrorStatus(cerr.Err, http.StatusConflict), true)
+	st.Assert(t, gock.IsDone(), true)
+}
+
+func TestTemporaryViewGone(t *testing.T) {
+	defer gock.Off()
+
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version1MockHost)
+	gock.New(host).
+		Post("/view_test_db/_temp_view").
+		Reply(410).
+		JSON(map[string]interface{}{"error": "gone", "reason": "Temporary views are not supported in CouchDB"})
+
+	mockTemporaryViewDesign(host, 200, 202)
+
+	// A CouchDB 1 connection to a newer server falls back to a design document
+	con := globalTestConnections.Version1(t, true)
+	db := con.Database("view_test_db")
+
+	result, err := db.TemporaryView("function(doc) { emit(doc.type) }").Execute(ViewParams{Reduce: False})
+	st.Assert(t, err, nil)
+	st.Assert(t, len(result.Rows), 1)
+	st.Assert(t, gock.IsDone(), true)
+}
+
 func TestNamedView(t *testing.T) {
 	defer gock.Off()
 
@@ -133,3 +255,216 @@ func TestViewReal(t *testing.T) {
 	st.Assert(t, result.TotalRows, float64(2))
 	st.Assert(t, result.Offset, float64(0))
 }
+
+func TestNamedViewKeys(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version1MockHost)).
+		Post("/view_test_db/_design/things/_view/byType").
+		MatchParam("reduce", "false").
+		BodyString(`{"keys":["fruit","vegetable"]}`).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 2,
+			"offset":     0,
+			"rows": []map[string]interface{}{
+				{
+					"id":    "fruit1",
+					"key":   "fruit",
+					"value": "apple",
+				},
+			},
+		})
+
+	con := globalTestConnections.Version1(t, true)
+	db := con.Database("view_test_db")
+	view := db.NamedView("things", "byType")
+
+	result, err := view.Execute(ViewParams{
+		Reduce: False,
+		Keys:   NewInterfaceListParameter([]interface{}{"fruit", "vegetable"}),
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, len(result.Rows), 1)
+	st.Assert(t, result.Rows[0].ID, "fruit1")
+}
+
+func TestNamedViewLongURL(t *testing.T) {
+	defer gock.Off()
+
+	longKey := strings.Repeat("k", maxViewURLLength)
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Post("/view_test_db/_design/things/_view/byType").
+		BodyString(fmt.Sprintf(`{"key":"%s","limit":10,"reduce":false}`, longKey)).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 2,
+			"offset":     0,
+			"rows":       []map[string]interface{}{},
+		})
+
+	con := globalTestConnections.Version3(t, true)
+	db := con.Database("view_test_db")
+	view := db.NamedView("things", "byType")
+
+	result, err := view.Execute(ViewParams{
+		Key:    NewInterfaceParameter(longKey),
+		Limit:  10,
+		Reduce: False,
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, result.TotalRows, float64(2))
+}
+
+func TestNamedViewLongURLVersion2(t *testing.T) {
+	defer gock.Off()
+
+	longKey := strings.Repeat("k", maxViewURLLength)
+
+	// CouchDB 2.0 & 2.1 would ignore the parameters in a POST body so they stay in the URL
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version2MockHost)).
+		Get("/view_test_db/_design/things/_view/byType").
+		MatchParam("key", `^"`+longKey+`"$`).
+		MatchParam("limit", "^10$").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 2,
+			"offset":     0,
+			"rows":       []map[string]interface{}{},
+		})
+
+	con := globalTestConnections.Version2(t, true)
+	db := con.Database("view_test_db")
+
+	result, err := db.NamedView("things", "byType").Execute(ViewParams{
+		Key:   NewInterfaceParameter(longKey),
+		Limit: 10,
+	})
+	st.Assert(t, err, nil)
+	st.Assert(t, result.TotalRows, float64(2))
+}
+
+func TestAllDocsViewKeys(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version1MockHost)).
+		Post("/view_test_db/_all_docs").
+		MatchParam("include_docs", "true").
+		BodyString(`{"keys":["fruit1"]}`).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 2,
+			"offset":     0,
+			"rows": []map[string]interface{}{
+				{
+					"id":    "fruit1",
+					"key":   "fruit1",
+					"value": map[string]string{"rev": DefaultFirstRev},
+					"doc":   map[string]string{"_id": "fruit1", "_rev": DefaultFirstRev},
+				},
+			},
+		})
+
+	con := globalTestConnections.Version1(t, true)
+	db := con.Database("view_test_db")
+
+	result, err := db.AllDocsView().Execute(ViewParams{
+		IncludeDocs: True,
+		Keys:        NewInterfaceListParameter([]interface{}{"fruit1"}),
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, len(result.Rows), 1)
+	st.Assert(t, result.Rows[0].HasDocument(), true)
+}
+
+func TestNamedViewExecuteMany(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(fmt.Sprintf("https://%s", globalTestConnections.Version3MockHost)).
+		Post("/view_test_db/_design/things/_view/byType/queries").
+		BodyString(`{"queries":[{"key":"fruit"},{"keys":["vegetable"],"limit":1}]}`).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"results": []map[string]interface{}{
+				{
+					"total_rows": 3,
+					"offset":     0,
+					"rows": []map[string]interface{}{
+						{"id": "fruit1", "key": "fruit", "value": "apple"},
+						{"id": "fruit2", "key": "fruit", "value": "kiwi"},
+					},
+				},
+				{
+					"total_rows": 3,
+					"offset":     2,
+					"rows": []map[string]interface{}{
+						{"id": "veg1", "key": "vegetable", "value": "carrot"},
+					},
+				},
+			},
+		})
+
+	con := globalTestConnections.Version3(t, true)
+	db := con.Database("view_test_db")
+	view := db.NamedView("things", "byType")
+
+	results, err := view.ExecuteMany([]ViewParams{
+		{Key: NewInterfaceParameter("fruit")},
+		{Keys: NewInterfaceListParameter([]interface{}{"vegetable"}), Limit: 1},
+	})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, len(results), 2)
+	st.Assert(t, len(results[0].Rows), 2)
+	st.Assert(t, results[1].Rows[0].ID, "veg1")
+	st.Assert(t, results[1].Offset, float64(2))
+}
+
+func TestAllDocsViewExecuteManyFallback(t *testing.T) {
+	defer gock.Off()
+
+	host := fmt.Sprintf("https://%s", globalTestConnections.Version1MockHost)
+
+	gock.New(host).
+		Post("/view_test_db/_all_docs/queries").
+		Reply(404).
+		JSON(map[string]string{"error": "not_found", "reason": "missing"})
+
+	gock.New(host).
+		Get("/view_test_db/_all_docs").
+		MatchParam("limit", "1").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 2,
+			"offset":     0,
+			"rows": []map[string]interface{}{
+				{"id": "fruit1", "key": "fruit1", "value": map[string]string{"rev": DefaultFirstRev}},
+			},
+		})
+
+	gock.New(host).
+		Get("/view_test_db/_all_docs").
+		MatchParam("skip", "1").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_rows": 2,
+			"offset":     1,
+			"rows": []map[string]interface{}{
+				{"id": "fruit2", "key": "fruit2", "value": map[string]string{"rev": DefaultFirstRev}},
+			},
+		})
+
+	con := globalTestConnections.Version1(t, true)
+	db := con.Database("view_test_db")
+
+	results, err := db.AllDocsView().ExecuteMany([]ViewParams{{Limit: 1}, {Skip: 1}})
+	st.Assert(t, err, nil)
+
+	st.Assert(t, len(results), 2)
+	st.Assert(t, results[0].Rows[0].ID, "fruit1")
+	st.Assert(t, results[1].Rows[0].ID, "fruit2")
+}